@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// checkLogin tries the credential against the smart http endpoint of the
+// repository, only a URL with the path of a repository can be checked
+func checkLogin(u *url.URL, username string, password string) error {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(u.String(), "/")+"/info/refs?service=git-upload-pack", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(username, password)
+	req.Header.Set("Git-Protocol", "version=2")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("the login check failed with %s", err)
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s rejected the credential with %s", u.Host, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("the login check failed with %s, is %s a repository?", resp.Status, u)
+	}
+	return nil
+}
+
+// helperConfig returns the helper entry for the git config with the options
+// of this invocation
+func helperConfig() string {
+	helper := []string{"1password"}
+	if account != "" {
+		helper = append(helper, "--account="+account)
+	}
+	if vault != "" {
+		helper = append(helper, "--vault="+shellQuote(vault))
+	}
+	if prefix != "" {
+		helper = append(helper, "--prefix="+shellQuote(prefix))
+	}
+	return strings.Join(helper, " ")
+}
+
+// AddHost creates the item of a host up front instead of waiting for the
+// first store: it asks for the missing username and token, checks them
+// against the repository and prints the git config for the host
+func AddHost(args []string) error {
+	flags := flag.NewFlagSet("add-host", flag.ExitOnError)
+	username := flags.String("username", "", "Username of the credential")
+	passwordStdin := flags.Bool("password-stdin", false, "Read the token or password from stdin")
+	noCheck := flags.Bool("no-check", false, "Do not check the credential against the repository")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: git credential-1password add-host [--username <name>] [--password-stdin] [--no-check] <url>")
+	}
+	u, err := url.Parse(flags.Arg(0))
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid url %q, expected e.g. https://gitlab.example.com/team/repo.git", flags.Arg(0))
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("invalid url %q, only http and https remotes use credentials", flags.Arg(0))
+	}
+	if err := checkHost(u.Host); err != nil {
+		return err
+	}
+	if u.User != nil && *username == "" {
+		*username = u.User.Username()
+	}
+	u.User = nil
+
+	inputs := Attributes{{"protocol", u.Scheme}, {"host", u.Host}, {"path", strings.TrimPrefix(u.Path, "/")}}
+	requestHost := routeHost(inputs)
+	if *username == "" {
+		*username = ask("Username:", hostConfig(requestHost).Username)
+	}
+	if *username == "" {
+		return fmt.Errorf("a username is required")
+	}
+	if _, item, err := lookupItem(vault, resolveHost(requestHost), requestHost, *username); err == nil && item.username() == *username {
+		return fmt.Errorf("%q already holds the credential of %s for %s, use reveal to show it", item.Title, *username, requestHost)
+	}
+
+	var password string
+	if *passwordStdin {
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		password = strings.TrimRight(line, "\r\n")
+	} else if password, err = askSecret("Token or password:"); err != nil {
+		return fmt.Errorf("%s, pass it with --password-stdin", err)
+	}
+	if password == "" {
+		return fmt.Errorf("a token or password is required")
+	}
+	// the same policy as for store
+	if err := checkToken(u.Host, password); err != nil && config.PasswordPolicy != "off" {
+		if config.PasswordPolicy == "refuse" {
+			return err
+		}
+		warnf("%s", err)
+	}
+
+	if *noCheck {
+		fmt.Println("Skipped the login check")
+	} else if strings.Trim(u.Path, "/") == "" {
+		fmt.Println("Skipped the login check, pass the URL of a repository to check the credential")
+	} else {
+		if err := checkLogin(u, *username, password); err != nil {
+			return err
+		}
+		fmt.Printf("Signed in to %s as %s\n", u.Host, *username)
+	}
+
+	ref := itemRef(vault, resolveHost(requestHost), *username)
+	inputs = append(inputs, Attribute{"username", *username}, Attribute{"password", password})
+	if err := checkCreateLimit(); err != nil {
+		return err
+	}
+	if err := createItem(ref.Vault, ref.Item, u.Scheme+"://"+u.Host, inputs); err != nil {
+		return fmt.Errorf("op item create failed with %s", friendlyError(err))
+	}
+	fmt.Printf("Created %q\n", ref.Item)
+
+	fmt.Println("\nAdd this to your git config (git config --global --edit):")
+	fmt.Printf("\n[credential %q]\n", u.Scheme+"://"+u.Host)
+	fmt.Printf("\thelper =\n\thelper = %s\n\tusername = %s\n", helperConfig(), *username)
+	return nil
+}
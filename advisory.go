@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minOpVersion is the oldest op CLI with the JSON output the helper
+// understands
+const minOpVersion = "2.0.0"
+
+// releaseRepo is the GitHub repository releases are fetched from
+var releaseRepo = "ethrgeist/git-credential-1password"
+
+// GitHubRelease is the subset of the GitHub releases API we need
+type GitHubRelease struct {
+	TagName    string `json:"tag_name"`
+	Prerelease bool   `json:"prerelease"`
+}
+
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// download fetches the url and returns its body, the context bounds the
+// request below the timeout of the client
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s failed with %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 100<<20))
+}
+
+// latestRelease fetches the latest release from GitHub
+func latestRelease(ctx context.Context) (GitHubRelease, error) {
+	var release GitHubRelease
+	raw, err := download(ctx, "https://api.github.com/repos/"+releaseRepo+"/releases/latest")
+	if err != nil {
+		return release, err
+	}
+	if err = json.Unmarshal(raw, &release); err != nil {
+		return release, fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	return release, nil
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// compareVersions compares two dotted versions like "2.30.0", a leading "v"
+// and pre-release suffixes are ignored
+func compareVersions(a string, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var na, nb int
+		if i < len(pa) {
+			na, _ = strconv.Atoi(strings.SplitN(pa[i], "-", 2)[0])
+		}
+		if i < len(pb) {
+			nb, _ = strconv.Atoi(strings.SplitN(pb[i], "-", 2)[0])
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// opVersion returns the version of the installed op CLI
+func opVersion() (string, error) {
+	cmd := exec.Command("op", "--version")
+	cmd.Env = opEnv()
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// advisories returns one line notes about an incompatible op CLI, an
+// outdated helper or competing credential helpers
+func advisories() []string {
+	notes := competingHelpers()
+	if v, err := opVersion(); err == nil && compareVersions(v, minOpVersion) < 0 {
+		notes = append(notes, fmt.Sprintf("op %s is not supported, please update the 1Password CLI to %s or later", v, minOpVersion))
+	}
+	current := getVersion()
+	if strings.HasPrefix(current, "v") {
+		// do not hold up the version for long when GitHub is slow
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if release, err := latestRelease(ctx); err == nil && !release.Prerelease && compareVersions(release.TagName, current) > 0 {
+			notes = append(notes, fmt.Sprintf("git-credential-1password %s is available (installed: %s)", release.TagName, current))
+		}
+	}
+	return notes
+}
+
+// PrintAdvisories prints the advisories to stderr, they are only checked
+// when asked for with --version, never while answering git
+func PrintAdvisories() {
+	for _, note := range advisories() {
+		fmt.Fprintf(os.Stderr, "git-credential-1password: %s\n", note)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Attribute is a key value pair of the credential protocol
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Attributes are the attributes of a request in the order git sent them,
+// attributes like capability[] or wwwauth[] may be sent several times
+type Attributes []Attribute
+
+// Get returns the value of the attribute, git uses the last one when a
+// single valued attribute is repeated
+func (a Attributes) Get(key string) string {
+	for i := len(a) - 1; i >= 0; i-- {
+		if a[i].Key == key {
+			return a[i].Value
+		}
+	}
+	return ""
+}
+
+// Has reports whether the attribute was sent, even with an empty value
+func (a Attributes) Has(key string) bool {
+	for _, attr := range a {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns all values of the attribute in the order they were sent
+func (a Attributes) Values(key string) []string {
+	var values []string
+	for _, attr := range a {
+		if attr.Key == key {
+			values = append(values, attr.Value)
+		}
+	}
+	return values
+}
+
+// Set returns a copy of the attributes with the values of the key replaced
+// by the value, the receiver may be shared and is never modified
+func (a Attributes) Set(key string, value string) Attributes {
+	result := make(Attributes, 0, len(a)+1)
+	set := false
+	for _, attr := range a {
+		if attr.Key != key {
+			result = append(result, attr)
+		} else if !set {
+			result = append(result, Attribute{key, value})
+			set = true
+		}
+	}
+	if !set {
+		result = append(result, Attribute{key, value})
+	}
+	return result
+}
+
+// attributesOf turns a map like the params of serve into attributes, in
+// the order of the keys
+func attributesOf(m map[string]string) Attributes {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make(Attributes, 0, len(m))
+	for _, k := range keys {
+		attrs = append(attrs, Attribute{k, m[k]})
+	}
+	return attrs
+}
+
+// parseAttributes reads attributes up to an empty line or the end of the
+// input, like git only the line ending is removed, spaces in values are
+// intentional and the value starts after the first "="
+func parseAttributes(r io.Reader) (Attributes, error) {
+	var attrs Attributes
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			return attrs, nil
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid input: %s", line)
+		}
+		attrs = append(attrs, Attribute{key, value})
+		if err != nil {
+			return attrs, nil
+		}
+	}
+}
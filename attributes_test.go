@@ -0,0 +1,164 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAttributes(t *testing.T) {
+	input := "protocol=https\r\n" +
+		"host=example.com\n" +
+		"password=  a=b %41 äö  \n" +
+		"wwwauth[]=Basic realm=\"example\"\n" +
+		"wwwauth[]=Bearer error=\"invalid_token\"\n" +
+		"empty=\n" +
+		"\n" +
+		"ignored=after the empty line\n"
+	attrs, err := parseAttributes(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Attributes{
+		{"protocol", "https"},
+		{"host", "example.com"},
+		{"password", "  a=b %41 äö  "},
+		{"wwwauth[]", "Basic realm=\"example\""},
+		{"wwwauth[]", "Bearer error=\"invalid_token\""},
+		{"empty", ""},
+	}
+	if !reflect.DeepEqual(attrs, want) {
+		t.Errorf("parseAttributes() = %q, want %q", attrs, want)
+	}
+	if got := attrs.Values("wwwauth[]"); len(got) != 2 {
+		t.Errorf("Values(wwwauth[]) = %q, want both values", got)
+	}
+
+	var buf bytes.Buffer
+	if err := newResponseWriter(&buf).WriteAttributes(attrs); err != nil {
+		t.Fatal(err)
+	}
+	roundTrip, err := parseAttributes(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roundTrip, want) {
+		t.Errorf("written and parsed again = %q, want %q", roundTrip, want)
+	}
+
+	if _, err := parseAttributes(strings.NewReader("no separator\n")); err == nil {
+		t.Error("parseAttributes() accepted a line without \"=\"")
+	}
+}
+
+func TestSpecialCharactersRoundTrip(t *testing.T) {
+	h := newHelperTest(t, "{}")
+	username := "dev=ops ü"
+	password := "  p=ss %41 wörd ✓  "
+	credential := "protocol=https\nhost=example.com\nusername=" + username + "\npassword=" + password + "\n\n"
+
+	if _, err := h.git(credential, "credential", "approve"); err != nil {
+		t.Fatal(err)
+	}
+	item := h.item("example.com")
+	if item == nil {
+		t.Fatal("approve did not create the item")
+	}
+	if item.field("username") != username || item.field("password") != password {
+		t.Errorf("stored %q / %q, want %q / %q", item.field("username"), item.field("password"), username, password)
+	}
+
+	out, err := h.git("protocol=https\nhost=example.com\n\n", "credential", "fill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "protocol=https\nhost=example.com\nusername=" + username + "\npassword=" + password + "\n"
+	if out != want {
+		t.Errorf("fill returned\n%q\nwant\n%q", out, want)
+	}
+
+	if _, err := h.git(credential, "credential", "reject"); err != nil {
+		t.Fatal(err)
+	}
+	if h.item("example.com") != nil {
+		t.Error("reject did not erase the item")
+	}
+	if out, err := h.git("protocol=https\nhost=example.com\n\n", "credential", "fill"); err == nil {
+		t.Errorf("fill after reject returned %q", out)
+	}
+}
+
+func TestCapabilityRoundTrip(t *testing.T) {
+	requireGit(t, "2.46.0")
+	h := newHelperTest(t, "{}")
+
+	credential := "capability[]=authtype\nprotocol=https\nhost=example.com\nauthtype=Bearer\ncredential=t0k=n\n\n"
+	if _, err := h.git(credential, "credential", "approve"); err != nil {
+		t.Fatal(err)
+	}
+	item := h.item("example.com")
+	if item == nil {
+		t.Fatal("approve did not create the item")
+	}
+	if item.field("password") != "t0k=n" || item.field("authtype") != "Bearer" {
+		t.Errorf("stored %q / %q, want the token with its authtype", item.field("password"), item.field("authtype"))
+	}
+
+	out, err := h.git("capability[]=authtype\nprotocol=https\nhost=example.com\n\n", "credential", "fill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"capability[]=authtype", "authtype=Bearer", "credential=t0k=n"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("fill returned %q, want %q", out, line)
+		}
+	}
+
+	// without the capability and a username git could not use the token
+	if out, err := h.git("protocol=https\nhost=example.com\n\n", "credential", "fill"); err == nil {
+		t.Errorf("fill without the authtype capability returned %q", out)
+	}
+}
+
+func TestEphemeralRoundTrip(t *testing.T) {
+	requireGit(t, "2.46.0")
+	h := newHelperTest(t, `{"hosts": {"example.com": {"authType": "Bearer", "ephemeral": true}}}`)
+
+	// short-lived credentials are never stored
+	credential := "capability[]=authtype\nprotocol=https\nhost=other.example.com\nauthtype=Bearer\ncredential=short\nephemeral=1\n\n"
+	if _, err := h.git(credential, "credential", "approve"); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.readDB().Items) != 0 {
+		t.Error("approve stored an ephemeral credential")
+	}
+
+	h.addItem("example.com", "https://example.com", "alice", "token")
+	out, err := h.git("capability[]=authtype\nprotocol=https\nhost=example.com\n\n", "credential", "fill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"authtype=Bearer", "credential=token", "ephemeral=1"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("fill returned %q, want %q", out, line)
+		}
+	}
+}
+
+func TestWWWAuthRoundTrip(t *testing.T) {
+	requireGit(t, "2.41.0")
+	h := newHelperTest(t, "{}")
+	h.addItem("example.com", "https://example.com", "alice", "secret")
+
+	input := "protocol=https\nhost=example.com\nwwwauth[]=Basic realm=\"example\"\nwwwauth[]=Bearer realm=\"example\"\n\n"
+	out, err := h.git(input, "credential", "fill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "username=alice\npassword=secret\n") {
+		t.Errorf("fill returned %q, want the credential", out)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+// Since git 2.46, helpers can pass a prepared credential, e.g. a bearer
+// token, with its auth scheme in authtype instead of username and password.
+// Git only sends and accepts these attributes with the authtype capability
+
+// hasCapability reports whether git announced the capability
+func hasCapability(inputs Attributes, name string) bool {
+	return contains(inputs.Values("capability[]"), name)
+}
+
+// authTypeInputs maps a credential git passed with authtype to the password
+// of the item, so it is stored like any other token
+func authTypeInputs(gitInputs Attributes) Attributes {
+	if gitInputs.Get("authtype") == "" || gitInputs.Get("credential") == "" {
+		return gitInputs
+	}
+	return gitInputs.Set("password", gitInputs.Get("credential"))
+}
+
+// authTypeAssignment returns the assignment statement persisting the
+// authtype on the item, nil when git sent none
+func authTypeAssignment(gitInputs Attributes) []string {
+	if gitInputs.Get("authtype") == "" {
+		return nil
+	}
+	return []string{"authtype[text]=" + gitInputs.Get("authtype")}
+}
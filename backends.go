@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// Backend is a way for op to reach 1Password, e.g. 1Password Connect, a
+// service account or the desktop app, set up by environment variables
+type Backend struct {
+	Name string `json:"name"`
+
+	// Env is set for op on top of its environment, values are expanded from
+	// the environment of the helper (e.g. "$CONNECT_TOKEN") so tokens need
+	// not be written to the config file, empty values remove the variable
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// BackendHealth tracks the recent results of a backend
+type BackendHealth struct {
+	LastServed  time.Time `json:"lastServed,omitempty"`
+	LastFailure time.Time `json:"lastFailure,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// failing reports whether the last request to the backend failed
+func (h BackendHealth) failing() bool {
+	return h.LastFailure.After(h.LastServed)
+}
+
+const (
+	// backendCooldown is how long a failed backend is tried last
+	backendCooldown = 5 * time.Minute
+	// backendServedResolution limits the writes of the state file for
+	// healthy backends
+	backendServedResolution = time.Minute
+)
+
+// backendEnv returns the environment of op for the backend
+func backendEnv(backend Backend) []string {
+	env := opEnv()
+	for name, value := range backend.Env {
+		kept := env[:0]
+		for _, kv := range env {
+			if n, _, _ := strings.Cut(kv, "="); n != name {
+				kept = append(kept, kv)
+			}
+		}
+		env = kept
+		if value = os.ExpandEnv(value); value != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// backendOrder returns the backends in the configured order, backends that
+// failed within the cooldown are tried last
+func backendOrder(health map[string]BackendHealth) []Backend {
+	var healthy, cooling []Backend
+	for _, backend := range config.Backends {
+		h := health[backend.Name]
+		if h.failing() && time.Since(h.LastFailure) < backendCooldown {
+			cooling = append(cooling, backend)
+		} else {
+			healthy = append(healthy, backend)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+// backendFailed reports whether op failed because of the backend, only then
+// the next backend is tried
+func backendFailed(err error) (string, bool) {
+	var helperErr *HelperError
+	if !errors.As(friendlyError(err), &helperErr) {
+		return "", false
+	}
+	switch helperErr.Code {
+	case "unreachable", "connect_token", "not_signed_in", "locked", "op_missing":
+		return helperErr.Code, true
+	}
+	return helperErr.Code, false
+}
+
+// setBackendHealth records the health of the backend in the state
+func setBackendHealth(name string, h BackendHealth) {
+	updateState(func(state *State) {
+		if state.Backends == nil {
+			state.Backends = make(map[string]BackendHealth)
+		}
+		state.Backends[name] = h
+	})
+}
+
+// runBackends runs op with the environment of each backend until one does
+// not fail because of the backend, without backends op runs once with its
+// usual environment
+func runBackends(run func(env []string) error) error {
+	if len(config.Backends) == 0 {
+		return run(opEnv())
+	}
+	health := loadState().Backends
+	var err error
+	for _, backend := range backendOrder(health) {
+		h := health[backend.Name]
+		err = run(backendEnv(backend))
+		code, failed := backendFailed(err)
+		if err == nil || !failed {
+			if h.failing() || time.Since(h.LastServed) >= backendServedResolution {
+				h.LastServed = time.Now()
+				setBackendHealth(backend.Name, h)
+			}
+			return err
+		}
+		h.LastFailure, h.LastError = time.Now(), code
+		setBackendHealth(backend.Name, h)
+		warnf("the backend %s failed with %s, trying the next one", backend.Name, code)
+	}
+	return err
+}
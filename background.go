@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cacheOnly restricts op commands to results cached by the daemon, so no
+// authorization prompt is triggered
+var cacheOnly bool
+
+var errNotCached = errors.New("not cached and 1Password is locked")
+
+// isBackground reports whether git was invoked by a non-interactive caller
+// like IDE auto-fetch or git maintenance, which must never trigger a prompt
+func isBackground() bool {
+	if v := os.Getenv("GIT_CREDENTIAL_1PASSWORD_BACKGROUND"); v != "" {
+		background, _ := strconv.ParseBool(v)
+		return background
+	}
+	// IDEs set this for background fetch and status, GIT_TERMINAL_PROMPT=0
+	// is not a hint since CI and scripts set it as well
+	if os.Getenv("GIT_OPTIONAL_LOCKS") == "0" {
+		return true
+	}
+	return ancestorIsMaintenance()
+}
+
+// ancestorIsMaintenance reports whether one of the parent processes is
+// "git maintenance", only supported on Linux
+func ancestorIsMaintenance() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	pid := os.Getppid()
+	for i := 0; i < 8 && pid > 1; i++ {
+		cmdline, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/cmdline")
+		if err != nil {
+			return false
+		}
+		args := strings.Split(string(cmdline), "\x00")
+		for _, arg := range args {
+			if arg == "maintenance" {
+				return true
+			}
+		}
+		stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil {
+			return false
+		}
+		// the parent pid is the second field after the command in parens
+		fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+		if len(fields) < 2 {
+			return false
+		}
+		pid, _ = strconv.Atoi(fields[1])
+	}
+	return false
+}
+
+// opUnlocked reports whether op can be used without an authorization
+// prompt, "op whoami" never prompts
+func opUnlocked() bool {
+	args := append([]string{"whoami"}, opFlags...)
+	cmd := exec.Command("op", args...)
+	cmd.Env = opEnv()
+	return cmd.Run() == nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// opElapsed is the time spent in op commands, it is used by bench to split
+// the latency into stages
+var opElapsed time.Duration
+
+// benchStages are the stages reported by bench
+var benchStages = []string{"op", "helper", "total"}
+
+// benchSample is the latency of a single get split into stages
+type benchSample map[string]time.Duration
+
+// benchGet runs a single lookup of the host and measures it
+func benchGet(host string) (benchSample, error) {
+	opElapsed = 0
+	start := time.Now()
+	requestHost := host
+	host = resolveHost(host)
+	_, item, err := lookupRecentItem(vault, host, requestHost, hostConfig(requestHost).Username)
+	if err != nil {
+		return nil, err
+	}
+	item.Fields.resolvePassword(hostConfig(requestHost))
+	total := time.Since(start)
+	return benchSample{"op": opElapsed, "helper": total - opElapsed, "total": total}, nil
+}
+
+// percentile returns the p-th percentile of the sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+// printBench prints p50 and p95 of every stage of the samples
+func printBench(backend string, samples []benchSample) {
+	for _, stage := range benchStages {
+		durations := make([]time.Duration, len(samples))
+		for i, sample := range samples {
+			durations[i] = sample[stage]
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		fmt.Printf("%-12s %-7s p50 %8s  p95 %8s\n", backend, stage,
+			percentile(durations, 50).Round(time.Microsecond), percentile(durations, 95).Round(time.Microsecond))
+	}
+}
+
+// Bench measures the latency of get for the host with op called directly
+// and through the daemon with a cold and a warm cache
+func Bench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	iterations := flags.Int("n", 10, "Number of iterations per backend")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *iterations < 1 {
+		return fmt.Errorf("usage: git credential-1password bench [-n <iterations>] <host>")
+	}
+	host := flags.Arg(0)
+
+	backends := []struct {
+		name   string
+		daemon bool
+		cold   bool
+	}{
+		{"cli", false, false},
+		{"daemon-cold", true, true},
+		{"daemon-warm", true, false},
+	}
+	for _, backend := range backends {
+		config.Daemon = backend.daemon
+		if backend.daemon {
+			if err := ensureDaemon(); err != nil {
+				return err
+			}
+			// prime the cache
+			if _, err := benchGet(host); err != nil {
+				return err
+			}
+		}
+		samples := make([]benchSample, 0, *iterations)
+		for i := 0; i < *iterations; i++ {
+			if backend.cold {
+				stopDaemon()
+				time.Sleep(50 * time.Millisecond)
+				if err := ensureDaemon(); err != nil {
+					return err
+				}
+			}
+			sample, err := benchGet(host)
+			if err != nil {
+				return err
+			}
+			samples = append(samples, sample)
+		}
+		printBench(backend.name, samples)
+	}
+	return nil
+}
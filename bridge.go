@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sourceLogin is the login of another CLI
+type sourceLogin struct {
+	host     string
+	username string
+	token    string
+}
+
+// tokenSource reads the login for a host from another CLI, an empty host
+// selects the default login of the CLI. The username is only resolved with
+// withUser, some CLIs need a network call for it
+type tokenSource func(host string, withUser bool) (sourceLogin, error)
+
+// tokenSources are the CLIs tokens can be imported from
+var tokenSources = map[string]tokenSource{
+	"gh":   ghToken,
+	"glab": glabToken,
+	"tea":  teaToken,
+}
+
+// runCLI runs a command and returns its trimmed standard output
+func runCLI(name string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s failed with %s\n%s", name, strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ghToken returns the login of the GitHub CLI for the host
+func ghToken(host string, withUser bool) (sourceLogin, error) {
+	if host == "" {
+		host = "github.com"
+	}
+	token, err := runCLI("gh", "auth", "token", "--hostname", host)
+	if err != nil || !withUser {
+		return sourceLogin{host: host, token: token}, err
+	}
+	username, err := runCLI("gh", "api", "user", "--hostname", host, "--jq", ".login")
+	if err != nil {
+		return sourceLogin{}, err
+	}
+	return sourceLogin{host, username, token}, nil
+}
+
+// glabToken returns the login of the GitLab CLI for the host
+func glabToken(host string, withUser bool) (sourceLogin, error) {
+	if host == "" {
+		host = "gitlab.com"
+	}
+	token, err := runCLI("glab", "config", "get", "token", "--host", host)
+	if err != nil || !withUser {
+		return sourceLogin{host: host, token: token}, err
+	}
+	raw, err := runCLI("glab", "api", "user", "--hostname", host)
+	if err != nil {
+		return sourceLogin{}, err
+	}
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err = json.Unmarshal([]byte(raw), &user); err != nil {
+		return sourceLogin{}, fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	return sourceLogin{host, user.Username, token}, nil
+}
+
+// teaLogins parses the logins of the Gitea CLI from its config file, only
+// the flat list of logins tea writes is understood
+func teaLogins() ([]map[string]string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "tea", "config.yml"))
+	if err != nil {
+		return nil, fmt.Errorf("reading the tea config failed with %s", err)
+	}
+	var logins []map[string]string
+	inLogins := false
+	for _, line := range strings.Split(string(raw), "\n") {
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			inLogins = strings.TrimSpace(line) == "logins:"
+			continue
+		}
+		if !inLogins {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "- ") {
+			logins = append(logins, make(map[string]string))
+			trimmed = strings.TrimPrefix(trimmed, "- ")
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok || len(logins) == 0 {
+			continue
+		}
+		logins[len(logins)-1][strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return logins, nil
+}
+
+// teaToken returns the login of the Gitea CLI for the host
+func teaToken(host string, withUser bool) (sourceLogin, error) {
+	logins, err := teaLogins()
+	if err != nil {
+		return sourceLogin{}, err
+	}
+	for _, login := range logins {
+		loginHost := urlHost(login["url"])
+		if host == loginHost || host == "" && login["default"] == "true" {
+			return sourceLogin{loginHost, login["user"], login["token"]}, nil
+		}
+	}
+	if host == "" {
+		return sourceLogin{}, fmt.Errorf("tea has no default login")
+	}
+	return sourceLogin{}, fmt.Errorf("tea has no login for %s", host)
+}
+
+// readSource reads the login of the host from the token source
+func readSource(name string, host string, withUser bool) (sourceLogin, error) {
+	source, ok := tokenSources[name]
+	if !ok {
+		return sourceLogin{}, fmt.Errorf("unknown token source %q, expected gh, glab or tea", name)
+	}
+	login, err := source(host, withUser)
+	if err != nil {
+		return sourceLogin{}, err
+	}
+	if login.token == "" {
+		return sourceLogin{}, fmt.Errorf("%s is not logged in to %s", name, login.host)
+	}
+	return login, nil
+}
+
+// sourceCredential reads the credential of the host from the token source
+func sourceCredential(name string, host string) (Attributes, error) {
+	login, err := readSource(name, host, true)
+	if err != nil {
+		return nil, err
+	}
+	return Attributes{{"protocol", "https"}, {"host", login.host}, {"username", login.username}, {"password", login.token}}, nil
+}
+
+// ImportToken stores the token another CLI is logged in with in 1Password, so
+// git and the CLI share one token
+func ImportToken(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: git credential-1password import <source> [<host>]")
+	}
+	host := ""
+	if len(args) == 2 {
+		host = args[1]
+	}
+	credential, err := sourceCredential(args[0], host)
+	if err != nil {
+		return err
+	}
+	if err := storeCredential(credential); err != nil {
+		return err
+	}
+	fmt.Printf("Stored the %s token of %s for %s\n", args[0], credential.Get("username"), credential.Get("host"))
+	return nil
+}
+
+// syncToken updates the item when the token source of the host has a
+// different token than 1Password and returns the current credential, the
+// item is returned unchanged when the source is unavailable
+func syncToken(hc HostConfig, host string, username string, password string) (string, string) {
+	name := hc.SyncFrom
+	if name == "" {
+		return username, password
+	}
+	// the username is only looked up for a new token, it may need the
+	// network
+	login, err := readSource(name, host, false)
+	if err != nil {
+		warnf("reading the token of %s failed with %s", name, err)
+		return username, password
+	}
+	if login.token == password {
+		return username, password
+	}
+	credential, err := sourceCredential(name, host)
+	if err != nil {
+		warnf("reading the token of %s failed with %s", name, err)
+		return username, password
+	}
+	if err := storeCredential(credential); err != nil {
+		warnf("updating the item with the token of %s failed with %s", name, err)
+	}
+	return credential.Get("username"), credential.Get("password")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ciMode is set with --ci, it masks secrets in CI logs and disables all
+// interactive paths
+var ciMode bool
+
+// inGitHubActions reports whether the helper runs in a GitHub Actions job
+func inGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// maskSecret registers the secret with the masking facility of the CI
+// runner, so it never shows up in the job log. GitHub Actions reads workflow
+// commands from the output of the step, stdout belongs to git, so the
+// command is written to stderr. GitLab CI can not mask values at runtime.
+func maskSecret(secret string) {
+	if !ciMode || secret == "" || !inGitHubActions() {
+		return
+	}
+	// every line of a multi-line secret is masked separately
+	for _, line := range strings.Split(secret, "\n") {
+		if line != "" {
+			fmt.Fprintf(os.Stderr, "::add-mask::%s\n", line)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// defaultClipboardClear is how long copied secrets stay on the clipboard,
+// like in the 1Password app
+const defaultClipboardClear = 90 * time.Second
+
+// clipboardCommands returns the commands to write and read the clipboard of
+// the system
+func clipboardCommands() (copyCmd []string, pasteCmd []string, err error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"pbcopy"}, []string{"pbpaste"}, nil
+	case "windows":
+		return []string{"clip"}, []string{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}, nil
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if _, err := exec.LookPath("wl-copy"); err == nil {
+			return []string{"wl-copy"}, []string{"wl-paste", "--no-newline"}, nil
+		}
+	}
+	if _, err := exec.LookPath("xclip"); err == nil {
+		return []string{"xclip", "-selection", "clipboard"}, []string{"xclip", "-selection", "clipboard", "-o"}, nil
+	}
+	if _, err := exec.LookPath("xsel"); err == nil {
+		return []string{"xsel", "--clipboard", "--input"}, []string{"xsel", "--clipboard", "--output"}, nil
+	}
+	return nil, nil, fmt.Errorf("no clipboard tool found, install wl-clipboard, xclip or xsel")
+}
+
+// writeClipboard puts the text on the clipboard
+func writeClipboard(text string) error {
+	copyCmd, _, err := clipboardCommands()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(copyCmd[0], copyCmd[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed with %s\n%s", copyCmd[0], err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// clipboardHash identifies a secret on the clipboard without keeping it
+// around
+func clipboardHash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// copySecret puts the secret on the clipboard and starts a background
+// process that clears it after the given duration
+func copySecret(secret string, clearAfter time.Duration) error {
+	if err := writeClipboard(secret); err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// only the hash is passed, through a pipe, so neither the secret nor
+	// its hash show up in the process list. It fits into the buffer of the
+	// pipe, so it is written before the child starts
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = w.WriteString(clipboardHash(secret))
+	w.Close()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "__clear-clipboard", clearAfter.String())
+	cmd.Stdin = r
+	detach(cmd)
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// ClearClipboard waits and clears the clipboard unless its content changed
+// in the meantime, the hash of the copied secret is read from stdin
+func ClearClipboard(args []string) {
+	if len(args) != 1 {
+		return
+	}
+	after, err := time.ParseDuration(args[0])
+	if err != nil {
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(os.Stdin, 128))
+	if err != nil {
+		return
+	}
+	hash := string(raw)
+	time.Sleep(after)
+
+	_, pasteCmd, err := clipboardCommands()
+	if err != nil {
+		return
+	}
+	current, err := exec.Command(pasteCmd[0], pasteCmd[1:]...).Output()
+	if err != nil {
+		return
+	}
+	if clipboardHash(strings.TrimRight(string(current), "\r\n")) == hash || clipboardHash(string(current)) == hash {
+		writeClipboard("")
+	}
+}
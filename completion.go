@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// completionFlags returns all flags as "--name"
+func completionFlags() string {
+	var flags []string
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, "--"+f.Name)
+	})
+	return strings.Join(flags, " ")
+}
+
+// completionActions returns the names of all actions
+func completionActions() string {
+	var names []string
+	for _, action := range actions {
+		names = append(names, action.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// completionHostActions returns the names of the actions completing hosts
+func completionHostActions(sep string) string {
+	var names []string
+	for _, action := range actions {
+		if action.hosts {
+			names = append(names, action.name)
+		}
+	}
+	return strings.Join(names, sep)
+}
+
+const bashCompletion = `# bash completion for git-credential-1password
+_git_credential_1password() {
+	local cur prev IFS=$'\n'
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	case "$prev" in
+	--vault)
+		COMPREPLY=($(compgen -W "$(git-credential-1password __complete vaults 2>/dev/null)" -- "$cur"))
+		return ;;
+	%s)
+		COMPREPLY=($(compgen -W "$(git-credential-1password __complete hosts 2>/dev/null)" -- "$cur"))
+		return ;;
+	completion)
+		COMPREPLY=($(compgen -W $'bash\nzsh\nfish' -- "$cur"))
+		return ;;
+	esac
+	IFS=' '
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -W "%s" -- "$cur"))
+	fi
+}
+complete -F _git_credential_1password git-credential-1password
+`
+
+const zshCompletion = `#compdef git-credential-1password
+autoload -U +X bashcompinit && bashcompinit
+`
+
+const fishCompletion = `# fish completion for git-credential-1password
+complete -c git-credential-1password -f
+complete -c git-credential-1password -n "__fish_use_subcommand" -a "%s"
+complete -c git-credential-1password -n "__fish_seen_subcommand_from %s" -a "(git-credential-1password __complete hosts 2>/dev/null)"
+complete -c git-credential-1password -n "__fish_seen_subcommand_from completion" -a "bash zsh fish"
+complete -c git-credential-1password -l vault -x -a "(git-credential-1password __complete vaults 2>/dev/null)"
+`
+
+// PrintCompletion prints the completion script for the given shell
+func PrintCompletion(args []string) error {
+	shell := "bash"
+	if len(args) > 0 {
+		shell = args[0]
+	}
+	bash := fmt.Sprintf(bashCompletion, completionHostActions("|"), completionFlags(), completionActions())
+	switch shell {
+	case "bash":
+		fmt.Print(bash)
+	case "zsh":
+		fmt.Print(zshCompletion + bash)
+	case "fish":
+		fmt.Printf(fishCompletion, completionActions(), completionHostActions(" "))
+	default:
+		return fmt.Errorf("unsupported shell %q, expected bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+// Complete prints the dynamic completion candidates, one per line. They come
+// from the state only, op is never run as it may prompt for authorization
+func Complete(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	state := loadState()
+	switch args[0] {
+	case "vaults":
+		// the vaults discovered last, even if outdated
+		for _, v := range state.Discovery[strings.Join(opFlags, " ")].Vaults {
+			fmt.Println(v.Name)
+		}
+	case "hosts":
+		// the hosts items were served for
+		seen := make(map[string]bool)
+		for key := range state.Items {
+			// the username of the key may contain an @ itself
+			seen[key[strings.LastIndex(key, "@")+1:]] = true
+		}
+		for host := range state.Vaults {
+			seen[host] = true
+		}
+		hosts := make([]string, 0, len(seen))
+		for host := range seen {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+		fmt.Println(strings.Join(hosts, "\n"))
+	}
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is the optional configuration file of the helper, it is read from
+// the path given by --config or from the default location in the users
+// config directory
+type Config struct {
+	// Aliases maps request hosts to credential hosts, keys may contain
+	// shell style wildcards, e.g. "*.internal.example": "sso.example"
+	Aliases map[string]string `json:"aliases,omitempty"`
+
+	// Backends are tried in order when op fails to reach 1Password, e.g.
+	// Connect first and a service account as fallback
+	Backends []Backend `json:"backends,omitempty"`
+
+	// Groups are named sets of hosts sharing one credential, so rotating
+	// the token of the group updates all its hosts at once
+	Groups map[string]HostGroup `json:"groups,omitempty"`
+
+	// Rules are regex match rules evaluated in order against the host, the
+	// first matching rule selects the item, see Rule
+	Rules []Rule `json:"rules,omitempty"`
+
+	// PasswordPolicy controls what happens on store when a password that
+	// does not look like a token is stored for a token-only host like
+	// github.com: "warn" (default), "refuse" or "off"
+	PasswordPolicy string `json:"passwordPolicy,omitempty"`
+
+	// HTTPPolicy controls whether credentials are returned for plaintext
+	// http remotes: "allow" (default), "warn-on-http" or "require-https",
+	// hosts can be exempted with allowHttp in their settings
+	HTTPPolicy string `json:"httpPolicy,omitempty"`
+
+	// PasswordFields is the order in which fields are tried as password,
+	// e.g. ["password", "credential", "label:token"], see passwordFields
+	PasswordFields []string `json:"passwordFields,omitempty"`
+
+	// FieldLabels maps a field name like "username" or "password" to the
+	// labels it has in other languages, e.g. {"password": ["Kennwort"]}
+	FieldLabels map[string][]string `json:"fieldLabels,omitempty"`
+
+	// SkipPasskeyItems skips items that only have a passkey and uses the
+	// next matching item instead
+	SkipPasskeyItems bool `json:"skipPasskeyItems,omitempty"`
+
+	// SearchVaults searches all vaults of the account when the item is not
+	// found in the configured vault
+	SearchVaults bool `json:"searchVaults,omitempty"`
+
+	// DiscoveryTTL is how long the list of vaults is cached
+	DiscoveryTTL Duration `json:"discoveryTTL,omitempty"`
+
+	// UsernameInTitle adds the username to the title of created items, so
+	// multiple accounts for the same host get distinct items
+	UsernameInTitle bool `json:"usernameInTitle,omitempty"`
+
+	// Daemon serves op commands through a long-lived daemon process which
+	// caches the results of read-only commands in memory
+	Daemon bool `json:"daemon,omitempty"`
+
+	// DaemonCacheTTL is how long the daemon caches results
+	DaemonCacheTTL Duration `json:"daemonCacheTTL,omitempty"`
+
+	// DaemonIdleTimeout is how long the daemon waits for requests before it
+	// exits
+	DaemonIdleTimeout Duration `json:"daemonIdleTimeout,omitempty"`
+
+	// UnlockWait is how long get waits for a locked 1Password to be
+	// unlocked before it gives up, "0s" fails right away
+	UnlockWait *Duration `json:"unlockWait,omitempty"`
+
+	// SessionKeyring keeps the op session token in the OS keyring for
+	// accounts without 1Password app integration
+	SessionKeyring bool `json:"sessionKeyring,omitempty"`
+
+	// OpCache sets the cache of op (OP_CACHE), by default it is enabled
+	// where op supports it, except in CI
+	OpCache *bool `json:"opCache,omitempty"`
+
+	// OpArgs are appended to every op invocation, before the arguments
+	// given with --op-arg
+	OpArgs []string `json:"opArgs,omitempty"`
+
+	// OfflineCache keeps retrieved credentials in a local cache encrypted
+	// with a key held in the OS keyring, which is used when 1Password can
+	// not be reached
+	OfflineCache bool `json:"offlineCache,omitempty"`
+
+	// OfflineCacheMaxAge is how long cached credentials may be served
+	OfflineCacheMaxAge Duration `json:"offlineCacheMaxAge,omitempty"`
+
+	// KeychainFallback also writes credentials to the OS keychain on store
+	// and reads them from there when 1Password is unavailable
+	KeychainFallback bool `json:"keychainFallback,omitempty"`
+
+	// PassEnv lists additional environment variables passed to op, by
+	// default only OP_* and the variables op needs to run are passed
+	PassEnv []string `json:"passEnv,omitempty"`
+
+	// Env sets additional environment variables for op
+	Env map[string]string `json:"env,omitempty"`
+
+	// ConfirmOverwrite asks before store overwrites the credential of a
+	// different username, without a terminal the store is refused
+	ConfirmOverwrite bool `json:"confirmOverwrite,omitempty"`
+
+	// WritableVault receives credentials that can not be stored in their
+	// vault because it is read-only
+	WritableVault string `json:"writableVault,omitempty"`
+
+	// MaxCreatesPerHour is the number of items store creates per hour
+	// before it requires --force, 0 uses the default of 20 and a negative
+	// value disables the limit
+	MaxCreatesPerHour int `json:"maxCreatesPerHour,omitempty"`
+
+	// ItemTemplate is the template of created items as json object, or the
+	// path of a json file, with extra fields, sections, tags or an icon
+	ItemTemplate json.RawMessage `json:"itemTemplate,omitempty"`
+
+	// Tag is added to all items created by the helper
+	Tag string `json:"tag,omitempty"`
+
+	// Tracing exports a trace of every action to an OpenTelemetry collector
+	Tracing *TracingConfig `json:"tracing,omitempty"`
+
+	// Hosts holds per host settings, keys may contain wildcards like aliases
+	Hosts map[string]HostConfig `json:"hosts,omitempty"`
+}
+
+// HostConfig holds the settings of a single host
+type HostConfig struct {
+	// Username is returned to git and used to select the item when git
+	// does not provide a username itself
+	Username string `json:"username,omitempty"`
+
+	// Ephemeral marks the credentials of the host as short-lived, git is
+	// told not to store them and they are not kept in the offline cache
+	Ephemeral bool `json:"ephemeral,omitempty"`
+
+	// TTL is how long cached credentials of the host may be served, after
+	// it they are resolved from 1Password again
+	TTL Duration `json:"ttl,omitempty"`
+
+	// Shape is the layout of the credential the host expects,
+	// "token-in-username" sends the token as username and Password as
+	// password, "token-in-password" allows an empty username
+	Shape string `json:"shape,omitempty"`
+
+	// Password is the fixed password sent with the token-in-username shape,
+	// e.g. "x-oauth-basic", empty by default
+	Password string `json:"password,omitempty"`
+
+	// AllowHTTP exempts the host from the httpPolicy
+	AllowHTTP bool `json:"allowHttp,omitempty"`
+
+	// SyncFrom keeps the item in sync with the token of a CLI like "gh",
+	// a changed token is stored in 1Password on get
+	SyncFrom string `json:"syncFrom,omitempty"`
+
+	// Exchange mints a short-lived access token from the token in the item
+	// on get, e.g. "artifactory", the item is never updated by store
+	Exchange string `json:"exchange,omitempty"`
+
+	// ExchangeTTL is the lifetime of exchanged tokens
+	ExchangeTTL Duration `json:"exchangeTTL,omitempty"`
+
+	// PasswordFields overrides the global passwordFields for the host
+	PasswordFields []string `json:"passwordFields,omitempty"`
+
+	// Document serves the credential from a Document item instead of a
+	// Login item, as its name or as op://vault/document
+	Document string `json:"document,omitempty"`
+
+	// DocumentFormat is netrc, credential or token, it is guessed from the
+	// content by default
+	DocumentFormat string `json:"documentFormat,omitempty"`
+
+	// AuthType sends the password as credential with this auth scheme,
+	// e.g. Bearer, when git supports the authtype capability
+	AuthType string `json:"authType,omitempty"`
+
+	// Header is a custom header the server authenticates with, e.g.
+	// Private-Token, git can only send it with http.extraHeader
+	Header string `json:"header,omitempty"`
+
+	// SSHKey is the SSH Key item with the deploy key of the host, as its
+	// name or as op://vault/item, by default the item titled like the host
+	SSHKey string `json:"sshKey,omitempty"`
+
+	// ItemTemplate overrides the global itemTemplate for the host
+	ItemTemplate json.RawMessage `json:"itemTemplate,omitempty"`
+}
+
+// HostGroup is a set of hosts resolving to a single item
+type HostGroup struct {
+	// Hosts are the members of the group, they may contain wildcards
+	Hosts []string `json:"hosts"`
+
+	// Item is the item of the group as title or op://vault/item, by default
+	// the item named after the group
+	Item string `json:"item,omitempty"`
+}
+
+// Rule maps hosts matching a regular expression to an item title or secret
+// reference, capture groups can be used in the item as $1 or ${name}
+type Rule struct {
+	Match string `json:"match"`
+	Item  string `json:"item"`
+
+	re *regexp.Regexp
+}
+
+var (
+	config     Config
+	configPath string
+	// credentialTTL is the ttl of the host of the current request
+	credentialTTL time.Duration
+)
+
+// defaultConfigPath returns the default location of the config file
+func defaultConfigPath() string {
+	return appFile(configDir, "config.json")
+}
+
+// loadConfig reads the config file at the given path, a missing file is not
+// an error unless the path was given explicitly
+func loadConfig(p string, explicit bool) error {
+	if p == "" {
+		return nil
+	}
+	var raw []byte
+	var err error
+	if isRemoteConfig(p) {
+		raw, err = readRemoteConfig(p)
+	} else {
+		raw, err = os.ReadFile(p)
+	}
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading config failed with %s", err)
+	}
+	if err = json.Unmarshal(raw, &config); err != nil {
+		return fmt.Errorf("parsing config %s failed with %s", p, err)
+	}
+	// typos like "valut" would be ignored silently otherwise
+	for _, issue := range checkConfig(raw) {
+		warnf("%s", formatIssue(p, issue))
+	}
+	for pattern := range config.Aliases {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid alias pattern %q in %s", pattern, p)
+		}
+	}
+	switch config.PasswordPolicy {
+	case "", "warn", "refuse", "off":
+	default:
+		return fmt.Errorf("invalid passwordPolicy %q in %s, expected warn, refuse or off", config.PasswordPolicy, p)
+	}
+	switch config.HTTPPolicy {
+	case "", "allow", "warn-on-http", "require-https":
+	default:
+		return fmt.Errorf("invalid httpPolicy %q in %s, expected allow, warn-on-http or require-https", config.HTTPPolicy, p)
+	}
+	for _, spec := range config.PasswordFields {
+		if err := checkFieldSpec(spec); err != nil {
+			return fmt.Errorf("%s in %s", err, p)
+		}
+	}
+	if err := checkFieldLabels(config.FieldLabels); err != nil {
+		return fmt.Errorf("%s in %s", err, p)
+	}
+	if err := checkItemTemplate(config.ItemTemplate); err != nil {
+		return fmt.Errorf("%s in %s", err, p)
+	}
+	for name, group := range config.Groups {
+		for _, pattern := range group.Hosts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid host pattern %q in group %q in %s", pattern, name, p)
+			}
+		}
+	}
+	for pattern, hc := range config.Hosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid host pattern %q in %s", pattern, p)
+		}
+		for _, spec := range hc.PasswordFields {
+			if err := checkFieldSpec(spec); err != nil {
+				return fmt.Errorf("%s for host %q in %s", err, pattern, p)
+			}
+		}
+		if err := checkItemTemplate(hc.ItemTemplate); err != nil {
+			return fmt.Errorf("%s for host %q in %s", err, pattern, p)
+		}
+		if _, ok := tokenSources[hc.SyncFrom]; hc.SyncFrom != "" && !ok {
+			return fmt.Errorf("invalid syncFrom %q for host %q in %s", hc.SyncFrom, pattern, p)
+		}
+		if _, ok := tokenExchanges[hc.Exchange]; hc.Exchange != "" && !ok {
+			return fmt.Errorf("invalid exchange %q for host %q in %s, expected artifactory", hc.Exchange, pattern, p)
+		}
+		if hc.DocumentFormat != "" && !contains(documentFormats, hc.DocumentFormat) {
+			return fmt.Errorf("invalid documentFormat %q for host %q in %s, expected %s", hc.DocumentFormat, pattern, p, strings.Join(documentFormats, ", "))
+		}
+		if hc.Shape != "" && !contains(shapes, hc.Shape) {
+			return fmt.Errorf("invalid shape %q for host %q in %s, expected %s", hc.Shape, pattern, p, strings.Join(shapes, " or "))
+		}
+	}
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.re, err = regexp.Compile(rule.Match); err != nil {
+			return fmt.Errorf("invalid rule %q in %s: %s", rule.Match, p, err)
+		}
+		if rule.Item == "" {
+			return fmt.Errorf("rule %q in %s has no item", rule.Match, p)
+		}
+	}
+	return nil
+}
+
+// matchRule returns the item of the first rule matching the host with capture
+// groups substituted
+func matchRule(host string) (ItemRef, bool) {
+	for _, rule := range config.Rules {
+		match := rule.re.FindStringSubmatchIndex(host)
+		if match == nil {
+			continue
+		}
+		item := rule.re.ExpandString(nil, rule.Item, host, match)
+		return parseItemRef(string(item)), true
+	}
+	return ItemRef{}, false
+}
+
+// matchHost looks up the host in a map whose keys may contain shell style
+// wildcards, exact keys win over wildcards and more specific (longer)
+// wildcards win over shorter ones
+func matchHost[T any](m map[string]T, host string) (T, bool) {
+	if v, ok := m[host]; ok {
+		return v, true
+	}
+
+	patterns := make([]string, 0, len(m))
+	for pattern := range m {
+		if strings.ContainsAny(pattern, "*?[") {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, host); ok {
+			return m[pattern], true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// groupMembers maps the hosts of all groups to the name of their group
+func groupMembers() map[string]string {
+	members := make(map[string]string)
+	for name, group := range config.Groups {
+		for _, host := range group.Hosts {
+			members[host] = name
+		}
+	}
+	return members
+}
+
+// resolveHost maps the host git asked for to the host the credential is
+// stored under, hosts of a group resolve to the name of the group
+func resolveHost(host string) string {
+	if target, ok := matchHost(config.Aliases, host); ok {
+		host = target
+	}
+	if name, ok := matchHost(groupMembers(), host); ok {
+		return name
+	}
+	return host
+}
+
+// hostConfig returns the settings for the host git asked for, falling back to
+// the settings of the host it resolves to
+func hostConfig(host string) HostConfig {
+	if hc, ok := matchHost(config.Hosts, host); ok {
+		return hc
+	}
+	hc, _ := matchHost(config.Hosts, resolveHost(host))
+	return hc
+}
+
+// routeHost returns the host git asked for, scoped to the organization like
+// "github.com/acme-corp" when git sends the path (credential.useHttpPath) and
+// the config has aliases, rules or settings for the organization
+func routeHost(inputs Attributes) string {
+	host := inputs.Get("host")
+	org, _, _ := strings.Cut(strings.TrimPrefix(inputs.Get("path"), "/"), "/")
+	// the path is not validated like the host, only plain names are used
+	if org == "" || checkHost(org) != nil {
+		return host
+	}
+	scoped := host + "/" + org
+	if _, ok := matchHost(config.Aliases, scoped); ok {
+		return scoped
+	}
+	if _, ok := matchHost(config.Hosts, scoped); ok {
+		return scoped
+	}
+	if _, ok := matchHost(groupMembers(), scoped); ok {
+		return scoped
+	}
+	if _, ok := matchRule(scoped); ok {
+		return scoped
+	}
+	return host
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// configIssue is a problem found in the config file, line is 0 when the
+// location is unknown
+type configIssue struct {
+	line    int
+	message string
+}
+
+// configChecker walks the raw config and checks it against the fields of
+// the Config struct, which is the schema of the config file
+type configChecker struct {
+	raw    []byte
+	dec    *json.Decoder
+	issues []configIssue
+	// lines maps the path of every key, e.g. "rules[0].match", to its line
+	lines map[string]int
+}
+
+// line returns the line of the current position of the decoder
+func (c *configChecker) line() int {
+	return bytes.Count(c.raw[:c.dec.InputOffset()], []byte("\n")) + 1
+}
+
+// jsonFields returns the fields of a struct by their json name
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if t.Field(i).IsExported() && name != "" && name != "-" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+	return fields
+}
+
+// suggestKey returns the known key closest to a misspelled one
+func suggestKey(key string, known map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for name := range known {
+		if d := levenshtein(strings.ToLower(key), strings.ToLower(name)); d < bestDistance || d == bestDistance && name < best {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// suggestOption returns the command line option closest to a key, options
+// like --vault are often mistaken for config keys
+func suggestOption(key string) string {
+	best, bestDistance := "", 3
+	flag.VisitAll(func(f *flag.Flag) {
+		if d := levenshtein(strings.ToLower(key), f.Name); d < bestDistance {
+			best, bestDistance = f.Name, d
+		}
+	})
+	return best
+}
+
+// walk checks the next value of the decoder against the type t
+func (c *configChecker) walk(t reflect.Type, p string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	tok, err := c.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		var fields map[string]reflect.Type
+		if t.Kind() == reflect.Struct {
+			fields = jsonFields(t)
+		}
+		seen := make(map[string]bool)
+		for c.dec.More() {
+			tok, err := c.dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+			keyPath := key
+			if p != "" {
+				keyPath = p + "." + key
+			}
+			line := c.line()
+			if seen[key] {
+				c.issues = append(c.issues, configIssue{line, fmt.Sprintf("duplicate key %q, only the last one is used", keyPath)})
+			}
+			seen[key] = true
+			c.lines[keyPath] = line
+
+			var valueType reflect.Type
+			switch t.Kind() {
+			case reflect.Struct:
+				valueType = fields[key]
+				if valueType == nil {
+					msg := fmt.Sprintf("unknown key %q", keyPath)
+					if suggestion := suggestKey(key, fields); suggestion != "" {
+						msg += fmt.Sprintf(", did you mean %q?", suggestion)
+					} else if option := suggestOption(key); p == "" && option != "" {
+						msg += fmt.Sprintf(", did you mean the command line option --%s?", option)
+					}
+					c.issues = append(c.issues, configIssue{line, msg})
+					valueType = reflect.TypeOf((*any)(nil)).Elem()
+				}
+			case reflect.Map:
+				valueType = t.Elem()
+			default:
+				valueType = reflect.TypeOf((*any)(nil)).Elem()
+			}
+			if err := c.walk(valueType, keyPath); err != nil {
+				return err
+			}
+		}
+		_, err = c.dec.Token()
+		return err
+	case json.Delim('['):
+		elem := reflect.TypeOf((*any)(nil)).Elem()
+		if t.Kind() == reflect.Slice {
+			elem = t.Elem()
+		}
+		for i := 0; c.dec.More(); i++ {
+			if err := c.walk(elem, fmt.Sprintf("%s[%d]", p, i)); err != nil {
+				return err
+			}
+		}
+		_, err = c.dec.Token()
+		return err
+	}
+	return nil
+}
+
+// checkConfig validates the raw config file: unknown and duplicate keys,
+// invalid patterns and regular expressions and rules that never match
+// because an earlier rule has the same expression
+func checkConfig(raw []byte) []configIssue {
+	c := &configChecker{raw: raw, dec: json.NewDecoder(bytes.NewReader(raw)), lines: make(map[string]int)}
+	if err := c.walk(reflect.TypeOf(Config{}), ""); err != nil && err != io.EOF {
+		line := 0
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			line = bytes.Count(raw[:syntaxErr.Offset], []byte("\n")) + 1
+		}
+		return append(c.issues, configIssue{line, fmt.Sprintf("invalid json: %s", err)})
+	}
+
+	var parsed Config
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return append(c.issues, configIssue{0, err.Error()})
+	}
+	for pattern := range parsed.Aliases {
+		if _, err := path.Match(pattern, ""); err != nil {
+			c.issues = append(c.issues, configIssue{c.lines["aliases."+pattern], fmt.Sprintf("invalid alias pattern %q", pattern)})
+		}
+	}
+	groups := make(map[string]string)
+	for name, group := range parsed.Groups {
+		line := c.lines[fmt.Sprintf("groups.%s.hosts", name)]
+		for _, pattern := range group.Hosts {
+			if _, err := path.Match(pattern, ""); err != nil {
+				c.issues = append(c.issues, configIssue{line, fmt.Sprintf("invalid host pattern %q in group %q", pattern, name)})
+			}
+			if other, ok := groups[pattern]; ok && other != name {
+				c.issues = append(c.issues, configIssue{line, fmt.Sprintf("host %q is in the groups %q and %q", pattern, other, name)})
+			}
+			groups[pattern] = name
+		}
+	}
+	for pattern := range parsed.Hosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			c.issues = append(c.issues, configIssue{c.lines["hosts."+pattern], fmt.Sprintf("invalid host pattern %q", pattern)})
+		}
+	}
+	matches := make(map[string]int)
+	for i, rule := range parsed.Rules {
+		rulePath := fmt.Sprintf("rules[%d]", i)
+		line := c.lines[rulePath+".match"]
+		if _, err := regexp.Compile(rule.Match); err != nil {
+			c.issues = append(c.issues, configIssue{line, fmt.Sprintf("invalid regular expression %q: %s", rule.Match, err)})
+		}
+		if rule.Item == "" {
+			c.issues = append(c.issues, configIssue{line, fmt.Sprintf("rule %q has no item", rule.Match)})
+		}
+		if first, ok := matches[rule.Match]; ok {
+			c.issues = append(c.issues, configIssue{line, fmt.Sprintf("rule %q never matches, rules[%d] has the same expression", rule.Match, first)})
+		} else {
+			matches[rule.Match] = i
+		}
+	}
+	sort.SliceStable(c.issues, func(i, j int) bool { return c.issues[i].line < c.issues[j].line })
+	return c.issues
+}
+
+// formatIssue formats an issue like a compiler message
+func formatIssue(p string, issue configIssue) string {
+	if issue.line == 0 {
+		return fmt.Sprintf("%s: %s", p, issue.message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p, issue.line, issue.message)
+}
+
+// ConfigCommand runs the config subcommands, "validate" checks the config
+// file and reports all issues with their line
+func ConfigCommand(args []string) error {
+	if len(args) != 1 || args[0] != "validate" {
+		return fmt.Errorf("usage: git credential-1password config validate")
+	}
+	var raw []byte
+	var err error
+	if isRemoteConfig(configPath) {
+		raw, err = readRemoteConfig(configPath)
+	} else {
+		raw, err = os.ReadFile(configPath)
+	}
+	if err != nil {
+		return fmt.Errorf("reading config failed with %s", err)
+	}
+
+	issues := checkConfig(raw)
+	for _, issue := range issues {
+		fmt.Println(formatIssue(configPath, issue))
+	}
+	if len(issues) == 0 {
+		// checks of the values, e.g. the policies, happen when loading
+		if err := loadConfig(configPath, true); err != nil {
+			return err
+		}
+		fmt.Printf("%s is valid\n", configPath)
+		return nil
+	}
+	return fmt.Errorf("%d issues found in %s", len(issues), configPath)
+}
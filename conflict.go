@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// conflictRetries is how often a conflicting edit is retried
+const conflictRetries = 4
+
+// conflictStatus matches the 409 Conflict status op reports for an edit of
+// an outdated item version, the words alone could be part of an item title
+var conflictStatus = regexp.MustCompile(`(?i)\(?\b409\b\)?\W{0,3}conflict\b`)
+
+// isEditConflict reports whether an edit failed because the item was
+// modified concurrently, e.g. by a parallel store of another CI job
+func isEditConflict(err error) bool {
+	if code := opErrorCode(err); code != "" {
+		return code == "edit_conflict"
+	}
+	msg := strings.ToLower(err.Error())
+	if conflictStatus.MatchString(msg) {
+		return true
+	}
+	for _, pattern := range []string{"item has been modified", "outdated version of the item", "item version mismatch"} {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryOnConflict runs the edit until it succeeds or fails for another
+// reason than a conflict. Every attempt re-reads the item, so the edits must
+// be idempotent
+func retryOnConflict(edit func() error) error {
+	err := edit()
+	for retry := 0; err != nil && retry < conflictRetries && isEditConflict(err); retry++ {
+		// jitter so parallel jobs do not collide again
+		time.Sleep(time.Duration(100+rand.Intn(400)) * time.Millisecond << retry)
+		err = edit()
+	}
+	return err
+}
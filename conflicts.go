@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// itemHosts returns the hosts an item would be used for, derived from its
+// title and its website entries
+func itemHosts(item OpListItem) []string {
+	var hosts []string
+	if title, ok := strings.CutPrefix(item.Title, prefix); ok {
+		title, _, _ = strings.Cut(title, titleSeparator)
+		hosts = append(hosts, strings.ToLower(title))
+	}
+	for _, u := range item.URLs {
+		if host := strings.ToLower(urlHost(u.Href)); host != "" && !contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// contains reports whether s is in list
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// findConflicts scans the vaults for hosts served by more than one item
+func findConflicts(vaults []string) (map[string][]OpListItem, error) {
+	conflicts := make(map[string][]OpListItem)
+	for _, v := range vaults {
+		items, err := opListItems(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			for _, host := range itemHosts(item) {
+				conflicts[host] = append(conflicts[host], item)
+			}
+		}
+	}
+	for host, items := range conflicts {
+		if len(items) < 2 {
+			delete(conflicts, host)
+		}
+	}
+	return conflicts, nil
+}
+
+// PrintConflicts prints all hosts served by more than one item together with
+// the vault and username of each item, best ranked item first
+func PrintConflicts() error {
+	vaults := []string{vault}
+	if config.SearchVaults {
+		vaults = append(vaults, searchVaults(vault)...)
+	}
+	conflicts, err := findConflicts(vaults)
+	if err != nil {
+		return err
+	}
+
+	hosts := make([]string, 0, len(conflicts))
+	for host := range conflicts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		items := conflicts[host]
+		rankItems(items)
+		fmt.Println(host)
+		for _, item := range items {
+			username := ""
+			if full, err := opGetItem(ItemRef{Vault: item.Vault.ID, Item: item.ID}); err == nil {
+				username = full.Fields.GetField("username")
+			}
+			fmt.Printf("  %s\tvault=%s\tusername=%s\tid=%s\n", item.Title, item.Vault.Name, username, item.ID)
+		}
+	}
+	if len(hosts) == 0 {
+		fmt.Println("no conflicting items found")
+	}
+	return nil
+}
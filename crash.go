@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// redactedConfig returns a copy of the config without values that may hold
+// secrets
+func redactedConfig() Config {
+	c := config
+	if len(c.Env) > 0 {
+		c.Env = make(map[string]string, len(config.Env))
+		for k := range config.Env {
+			c.Env[k] = "<redacted>"
+		}
+	}
+	if config.Tracing != nil {
+		tracing := *config.Tracing
+		tracing.Headers = make(map[string]string, len(config.Tracing.Headers))
+		for k := range config.Tracing.Headers {
+			tracing.Headers[k] = "<redacted>"
+		}
+		c.Tracing = &tracing
+	}
+	c.Backends = make([]Backend, len(config.Backends))
+	for i, backend := range config.Backends {
+		c.Backends[i] = Backend{Name: backend.Name, Env: make(map[string]string, len(backend.Env))}
+		for k := range backend.Env {
+			c.Backends[i].Env[k] = "<redacted>"
+		}
+	}
+	if len(c.Hosts) > 0 {
+		c.Hosts = make(map[string]HostConfig, len(config.Hosts))
+		for pattern, hc := range config.Hosts {
+			if hc.Password != "" {
+				hc.Password = "<redacted>"
+			}
+			hc.ItemTemplate = redactedTemplate(hc.ItemTemplate)
+			c.Hosts[pattern] = hc
+		}
+	}
+	c.ItemTemplate = redactedTemplate(config.ItemTemplate)
+	c.OpArgs = make([]string, len(config.OpArgs))
+	for i, arg := range config.OpArgs {
+		c.OpArgs[i] = redactOpArg(arg)
+	}
+	return c
+}
+
+// redactedTemplate returns the item template with the values of its fields
+// redacted, the path of a template file is kept as it is
+func redactedTemplate(raw json.RawMessage) json.RawMessage {
+	var template map[string]any
+	if len(raw) == 0 || json.Unmarshal(raw, &template) != nil {
+		return raw
+	}
+	fields, _ := template["fields"].([]any)
+	for _, field := range fields {
+		if f, ok := field.(map[string]any); ok && f["value"] != nil {
+			f["value"] = "<redacted>"
+		}
+	}
+	redacted, err := json.Marshal(template)
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// redactOpArg removes the value of an argument for op, flags are kept by
+// name, anything else may be the value of the previous flag
+func redactOpArg(arg string) string {
+	if name, _, hasValue := strings.Cut(arg, "="); hasValue {
+		return name + "=<redacted>"
+	}
+	if !strings.HasPrefix(arg, "-") {
+		return "<redacted>"
+	}
+	return arg
+}
+
+// redactedArgs returns the command line with the values of --op-arg
+// redacted like the opArgs of the config
+func redactedArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		out[i] = arg
+		if i > 0 && (args[i-1] == "--op-arg" || args[i-1] == "-op-arg") {
+			out[i] = redactOpArg(arg)
+		} else if flagName, value, ok := strings.Cut(arg, "="); ok && (flagName == "--op-arg" || flagName == "-op-arg") {
+			out[i] = flagName + "=" + redactOpArg(value)
+		}
+	}
+	return out
+}
+
+// writeCrashReport writes a diagnostic bundle for the panic to a temp file
+// and returns its path, secrets like the request of git are never included
+func writeCrashReport(value any, stack []byte) (string, error) {
+	f, err := os.CreateTemp("", "git-credential-1password-crash-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(f, "panic: %v\n\n", value)
+	fmt.Fprintf(f, "version: %s\n", version)
+	fmt.Fprintf(f, "go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if v, err := opVersion(); err == nil {
+		fmt.Fprintf(f, "op: %s\n", v)
+	} else {
+		fmt.Fprintf(f, "op: %s\n", err)
+	}
+	fmt.Fprintf(f, "args: %q\n", redactedArgs(os.Args[1:]))
+	fmt.Fprintf(f, "config: %s\n", configPath)
+	raw, _ := json.MarshalIndent(redactedConfig(), "", "  ")
+	fmt.Fprintf(f, "%s\n\n", raw)
+	f.Write(stack)
+	return f.Name(), nil
+}
+
+// recoverPanic turns a panic into a short message pointing to a diagnostic
+// bundle that can be attached to a bug report
+func recoverPanic() {
+	value := recover()
+	if value == nil {
+		return
+	}
+	stack := debug.Stack()
+	fmt.Fprintf(os.Stderr, "git-credential-1password crashed: %v\n", value)
+	if p, err := writeCrashReport(value, stack); err == nil {
+		fmt.Fprintf(os.Stderr, "A diagnostic report was written to %s\n", p)
+		fmt.Fprintln(os.Stderr, "Please attach it to an issue at https://github.com/ethrgeist/git-credential-1password/issues")
+	} else {
+		os.Stderr.Write(stack)
+	}
+	os.Exit(2)
+}
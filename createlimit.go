@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultMaxCreatesPerHour is the number of items store creates per hour
+// before it asks for --force
+const defaultMaxCreatesPerHour = 20
+
+// maxCreatesPerHour returns the configured limit, 0 uses the default and a
+// negative value disables the limit
+func maxCreatesPerHour() int {
+	if config.MaxCreatesPerHour == 0 {
+		return defaultMaxCreatesPerHour
+	}
+	return config.MaxCreatesPerHour
+}
+
+// recentCreates returns the creations of the last hour
+func recentCreates(state State) []time.Time {
+	var recent []time.Time
+	for _, t := range state.Created {
+		if time.Since(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	return recent
+}
+
+// checkCreateLimit refuses to create another item when the limit of the
+// last hour is reached, a script looping over hundreds of hosts through a
+// misconfigured helper would otherwise flood the vault
+func checkCreateLimit() error {
+	limit := maxCreatesPerHour()
+	if limit < 0 || force {
+		return nil
+	}
+	if n := len(recentCreates(loadState())); n >= limit {
+		return &HelperError{
+			Code:    "create_limit",
+			Message: fmt.Sprintf("refusing to create another item, %d items were created in the last hour", n),
+			Hint:    "use --force if this is intended or raise maxCreatesPerHour in the config",
+		}
+	}
+	return nil
+}
+
+// recordCreate remembers the creation of an item for the limit
+func recordCreate() {
+	updateState(func(state *State) {
+		state.Created = append(recentCreates(*state), time.Now())
+	})
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Credential is the answer to a get request
+type Credential struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	Ephemeral bool   `json:"ephemeral,omitempty"`
+	// AuthType is the auth scheme of the password, e.g. Bearer, it is only
+	// set when git supports the authtype capability
+	AuthType string `json:"authtype,omitempty"`
+}
+
+// requestIdentity returns the host and username a request of git is
+// resolved with: the username of the remote URL or credential.username, or
+// the username of the host settings. get, store and erase all use it, so
+// they target the same item for an identity
+func requestIdentity(gitInputs Attributes) (string, string) {
+	host := routeHost(gitInputs)
+	username := gitInputs.Get("username")
+	if username == "" {
+		username = hostConfig(host).Username
+	}
+	return host, username
+}
+
+// getCredential looks up the credential for the request of git, nil is
+// returned when a background request can not be answered from the cache
+func getCredential(gitInputs Attributes) (*Credential, error) {
+	// git can not send custom headers from the answer of a helper
+	if header := hostConfig(routeHost(gitInputs)).Header; header != "" {
+		host := gitInputs.Get("host")
+		return nil, &HelperError{
+			Code:    "custom_header",
+			Message: fmt.Sprintf("%s authenticates with the %s header, which git can not send from a credential helper", host, header),
+			Hint:    fmt.Sprintf("pass it with http.extraHeader in the environment, never with git -c: GIT_CONFIG_COUNT=1 GIT_CONFIG_KEY_0=http.https://%s/.extraHeader GIT_CONFIG_VALUE_0=\"$(git credential-1password header %s)\" git ...", host, host),
+		}
+	}
+	return resolveCredential(gitInputs)
+}
+
+// resolveCredential looks up the credential like getCredential does,
+// without the checks of the layout the host expects
+func resolveCredential(gitInputs Attributes) (*Credential, error) {
+	// check if the host field is present in the input
+	if !gitInputs.Has("host") {
+		return nil, fmt.Errorf("host is missing in the input")
+	}
+	if err := checkHost(gitInputs.Get("host")); err != nil {
+		return nil, err
+	}
+
+	// protect credentials from plaintext remotes
+	if gitInputs.Get("protocol") == "http" && !hostConfig(gitInputs.Get("host")).AllowHTTP {
+		switch config.HTTPPolicy {
+		case "require-https":
+			return nil, fmt.Errorf("refusing to send the credential of %s over plaintext http, use https or set allowHttp for the host", gitInputs.Get("host"))
+		case "warn-on-http":
+			warnf("the credential of %s is sent over plaintext http", gitInputs.Get("host"))
+		}
+	}
+
+	// a configured username selects the item and is returned to git
+	// instead of the username of the item
+	host, requestUsername := requestIdentity(gitInputs)
+	configUsername := hostConfig(host).Username
+	credentialTTL = time.Duration(hostConfig(host).TTL)
+
+	// run "op item get --format json" command with the host value
+	// this can only get, no other operations are allowed
+	var opItem *OpFullItem
+	var err error
+	if hostConfig(host).Document != "" {
+		opItem, err = documentItem(hostConfig(host), gitInputs.Get("host"))
+	} else {
+		_, opItem, err = lookupRecentItem(vault, resolveHost(host), host, requestUsername)
+	}
+	if err != nil && cacheOnly {
+		// answer nothing instead of failing the background operation
+		return nil, nil
+	}
+	offlineKey := stateKey(host, requestUsername)
+	if err != nil {
+		// serve a credential from the offline cache or the OS keychain
+		// while 1Password is unavailable
+		if fallback := fallbackItem(offlineKey, err); fallback != nil {
+			opItem, err = fallback, nil
+		}
+	}
+	if err != nil {
+		return nil, friendlyError(err)
+	}
+
+	// feed the username and password to git
+	username := opItem.Fields.GetField("username")
+	if gitInputs.Get("username") == "" && configUsername != "" {
+		username = configUsername
+	}
+	// token items often have no username, git knows it from the url or
+	// credential.username
+	if username == "" {
+		username = gitInputs.Get("username")
+	}
+	hc := hostConfig(host)
+	password, _ := opItem.Fields.resolvePassword(hc)
+	if !cacheOnly && opItem.ID != "" {
+		username, password = syncToken(hc, gitInputs.Get("host"), username, password)
+	}
+	if opItem.passkeyOnly(hc) {
+		return nil, passkeyError(opItem)
+	}
+	// the authtype git stored with the credential, e.g. Bearer
+	authType := opItem.Fields.GetField("authtype")
+	if hc.AuthType != "" {
+		authType = hc.AuthType
+	}
+	if authType != "" && !hasCapability(gitInputs, "authtype") {
+		if username == "" {
+			return nil, fmt.Errorf("the credential of %s is used with authtype %s, which requires git 2.46 or later", gitInputs.Get("host"), authType)
+		}
+		authType = ""
+	}
+	// the username is only required when the host expects a login
+	if password == "" || username == "" && hc.Shape == "" && authType == "" && hc.Header == "" {
+		return nil, fmt.Errorf("username or password is empty, is the item named correctly?")
+	}
+	recordServed(opItem.ID)
+	ephemeral := hc.Ephemeral
+	if config.OfflineCache && opItem.ID != "" && !ephemeral {
+		if err := saveOffline(offlineKey, username, password); err != nil {
+			warnf("updating the offline cache failed with %s", err)
+		}
+	}
+	if hc.Exchange != "" {
+		token, err := exchangeToken(hc, gitInputs.Get("host"), username, password)
+		if err != nil {
+			return nil, err
+		}
+		password, ephemeral = token, true
+	}
+	maskSecret(password)
+	if authType != "" {
+		return &Credential{Password: password, Ephemeral: ephemeral, AuthType: authType}, nil
+	}
+	username, password = shapeCredential(hc, username, password)
+	return &Credential{Username: username, Password: password, Ephemeral: ephemeral}, nil
+}
+
+// eraseTarget returns the item to delete for the host, with a username only
+// the item of that account qualifies so the other accounts of the host are
+// left untouched
+func eraseTarget(requestHost string, username string) (ItemRef, error) {
+	host := resolveHost(requestHost)
+	if username == "" {
+		// run "op delete item" command with the id of the item titled
+		// like the host, titles are subject to ambiguous matching
+		return opResolveItem(itemRef(vault, host, ""))
+	}
+	_, item, err := lookupRecentItem(vault, host, requestHost, username)
+	if err != nil {
+		return ItemRef{}, err
+	}
+	// items without username are served with the username of git or the
+	// host settings
+	if existing := item.Fields.GetField("username"); existing != "" && existing != username {
+		return ItemRef{}, fmt.Errorf("no item with username %q found for %s", username, requestHost)
+	}
+	return ItemRef{Vault: item.Vault.ID, Item: item.ID}, nil
+}
+
+// eraseCredential deletes the item of the credential git rejected
+func eraseCredential(gitInputs Attributes) error {
+	if err := checkHost(gitInputs.Get("host")); err != nil {
+		return err
+	}
+	if config.KeychainFallback {
+		eraseKeychain(routeHost(gitInputs), gitInputs.Get("username"))
+	}
+	// never delete a document shared by the team, or the long-lived
+	// identity token of a host git only knows the exchanged token of
+	if hc := hostConfig(routeHost(gitInputs)); hc.Document != "" || hc.Exchange != "" {
+		return nil
+	}
+	// the pinned item was chosen deliberately for the repository
+	if pinnedItem != "" {
+		return nil
+	}
+	ref, err := eraseTarget(requestIdentity(gitInputs))
+	if err != nil {
+		return nil
+	}
+	if _, err := runOp(buildOpItemCommand("delete", ref.Vault, ref.Item)); err != nil && isPermissionDenied(err) {
+		warnf("the vault of %q is read-only, the credential was not erased", ref.Item)
+	}
+	return nil
+}
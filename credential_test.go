@@ -0,0 +1,102 @@
+//go:build unix
+
+package main
+
+import "testing"
+
+func TestGetEchoesUnknownAttributes(t *testing.T) {
+	h := newHelperTest(t, "{}")
+	h.addItem("svn.example.com", "https://svn.example.com", "alice", "secret")
+
+	input := "protocol=https\nhost=svn.example.com\npath=repos/trunk\nrealm=<https://svn.example.com:443> Subversion\nx-deploy=1\nx-deploy=2\n\n"
+	out, err := h.helper(input, "get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "username=alice\npassword=secret\nrealm=<https://svn.example.com:443> Subversion\nx-deploy=1\nx-deploy=2\n"
+	if out != want {
+		t.Errorf("get returned\n%q\nwant\n%q", out, want)
+	}
+}
+
+func TestGetUnknownHost(t *testing.T) {
+	h := newHelperTest(t, "{}")
+	if out, err := h.helper("protocol=https\nhost=unknown.example.com\nx-deploy=1\n\n", "get"); err == nil || out != "" {
+		t.Errorf("get of an unknown host returned %q, %v, want an error and no output", out, err)
+	}
+}
+
+func TestStoreAndErase(t *testing.T) {
+	h := newHelperTest(t, "{}")
+
+	input := "protocol=https\nhost=deploy.example.com\npath=team/app.git\nusername=deployer\npassword=first\nx-deploy=1\n\n"
+	if _, err := h.helper(input, "store"); err != nil {
+		t.Fatal(err)
+	}
+	item := h.item("deploy.example.com")
+	if item == nil {
+		t.Fatal("store did not create the item")
+	}
+	if item.field("username") != "deployer" || item.field("password") != "first" {
+		t.Errorf("stored %q / %q, want deployer / first", item.field("username"), item.field("password"))
+	}
+	if len(item.URLs) != 1 || item.URLs[0].Href != "https://deploy.example.com" {
+		t.Errorf("stored websites %v, want https://deploy.example.com", item.URLs)
+	}
+
+	// a new password of the same user updates the item
+	input = "protocol=https\nhost=deploy.example.com\nusername=deployer\npassword=second\n\n"
+	if _, err := h.helper(input, "store"); err != nil {
+		t.Fatal(err)
+	}
+	if items := h.readDB().Items; len(items) != 1 || items[0].field("password") != "second" {
+		t.Errorf("store of a new password left %d items, want the item updated", len(items))
+	}
+
+	input = "protocol=https\nhost=deploy.example.com\nusername=deployer\npassword=second\nx-deploy=1\n\n"
+	if _, err := h.helper(input, "erase"); err != nil {
+		t.Fatal(err)
+	}
+	if h.item("deploy.example.com") != nil {
+		t.Error("erase did not delete the item")
+	}
+}
+
+func TestEraseUnknownHost(t *testing.T) {
+	h := newHelperTest(t, "{}")
+	h.addItem("example.com", "https://example.com", "alice", "secret")
+
+	if _, err := h.helper("protocol=https\nhost=unknown.example.com\n\n", "erase"); err != nil {
+		t.Fatal(err)
+	}
+	if len(h.readDB().Items) != 1 {
+		t.Error("erase of an unknown host deleted an item")
+	}
+}
+
+func TestEraseOtherUsername(t *testing.T) {
+	h := newHelperTest(t, "{}")
+	h.addItem("example.com", "https://example.com", "alice", "secret")
+
+	// the credential of another account is not the one git rejected
+	if _, err := h.helper("protocol=https\nhost=example.com\nusername=bob\npassword=secret\n\n", "erase"); err != nil {
+		t.Fatal(err)
+	}
+	if h.item("example.com") == nil {
+		t.Error("erase deleted the item of another username")
+	}
+}
+
+func TestFillURL(t *testing.T) {
+	h := newHelperTest(t, "{}")
+	h.addItem("example.com", "https://example.com", "alice", "secret")
+
+	out, err := h.git("url=https://alice@example.com/team/app.git\n\n", "credential", "fill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "protocol=https\nhost=example.com\nusername=alice\npassword=secret\n"
+	if out != want {
+		t.Errorf("fill returned\n%q\nwant\n%q", out, want)
+	}
+}
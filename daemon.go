@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultDaemonCacheTTL is used when the config does not set daemonCacheTTL
+	defaultDaemonCacheTTL = 5 * time.Minute
+	// defaultDaemonIdleTimeout is used when the config does not set
+	// daemonIdleTimeout
+	defaultDaemonIdleTimeout = 15 * time.Minute
+)
+
+// daemonRequest is sent by the front-end to run an op command
+type daemonRequest struct {
+	Args  []string `json:"args"`
+	Stdin []byte   `json:"stdin,omitempty"`
+	// CacheOnly answers only from the cache and never runs op
+	CacheOnly bool `json:"cacheOnly,omitempty"`
+	// MaxAge limits the age of cached results, zero uses the cache ttl
+	MaxAge time.Duration `json:"maxAge,omitempty"`
+	// Shutdown stops the daemon
+	Shutdown bool `json:"shutdown,omitempty"`
+}
+
+// daemonResult is the outcome of an op command run by the daemon
+type daemonResult struct {
+	Stdout []byte `json:"stdout,omitempty"`
+	Stderr []byte `json:"stderr,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// output returns the result like execOp does
+func (r daemonResult) output() ([]byte, error) {
+	if r.Error != "" {
+		return r.Stdout, fmt.Errorf("%s\n%s", r.Error, bytes.TrimSpace(r.Stderr))
+	}
+	if verbose {
+		os.Stderr.Write(r.Stderr)
+	}
+	return r.Stdout, nil
+}
+
+// daemonSocketPath returns the location of the daemon socket
+func daemonSocketPath() string {
+	return appFile(runtimeDir, "daemon.sock")
+}
+
+// daemonRunOp lets the daemon run the op command, if no daemon is running,
+// one is started for the next invocation and false is returned
+func daemonRunOp(args []string, stdin []byte, cacheOnly bool) (daemonResult, bool) {
+	var result daemonResult
+	conn, err := net.DialTimeout("unix", daemonSocketPath(), 100*time.Millisecond)
+	if err != nil {
+		if !cacheOnly {
+			startDaemon()
+		}
+		return result, false
+	}
+	defer conn.Close()
+	if err = checkPeer(conn); err != nil {
+		warnf("%s", err)
+		return result, false
+	}
+
+	if err = json.NewEncoder(conn).Encode(daemonRequest{Args: args, Stdin: stdin, CacheOnly: cacheOnly, MaxAge: credentialTTL}); err != nil {
+		return result, false
+	}
+	// the result is base64 encoded in JSON
+	if err = json.NewDecoder(io.LimitReader(conn, 2*maxOpOutput)).Decode(&result); err != nil {
+		return result, false
+	}
+	return result, true
+}
+
+// startDaemon starts the daemon in the background with the same options as
+// the current invocation
+func startDaemon() {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	options := os.Args[1 : len(os.Args)-flag.NArg()]
+	cmd := exec.Command(exe, append(options, "daemon")...)
+	detach(cmd)
+	if err = cmd.Start(); err == nil {
+		cmd.Process.Release()
+	}
+}
+
+// cacheEntry is a cached result of a read-only op command
+type cacheEntry struct {
+	result  daemonResult
+	fetched time.Time
+	expires time.Time
+}
+
+// daemon caches the results of read-only op commands in memory
+type daemon struct {
+	mu       sync.Mutex
+	cache    map[string]cacheEntry
+	ttl      time.Duration
+	listener net.Listener
+}
+
+// readOnly reports whether the op command does not modify anything
+func readOnly(args []string) bool {
+	if len(args) < 2 {
+		return false
+	}
+	switch args[0] + " " + args[1] {
+	case "item get", "item list", "vault list":
+		return true
+	}
+	return false
+}
+
+// run runs the op command or serves it from the cache, any modifying
+// command flushes the cache
+func (d *daemon) run(req daemonRequest) daemonResult {
+	key := strings.Join(req.Args, "\x00")
+	cacheable := readOnly(req.Args) && len(req.Stdin) == 0
+
+	d.mu.Lock()
+	if !cacheable && !req.CacheOnly {
+		d.cache = make(map[string]cacheEntry)
+	} else if entry, ok := d.cache[key]; ok && time.Now().Before(entry.expires) &&
+		(req.MaxAge == 0 || time.Since(entry.fetched) < req.MaxAge) {
+		d.mu.Unlock()
+		return entry.result
+	}
+	d.mu.Unlock()
+	if req.CacheOnly {
+		return daemonResult{Error: errNotCached.Error()}
+	}
+
+	var result daemonResult
+	var stdout *limitedBuffer
+	var stderr *truncatingBuffer
+	var runErr error
+	runOnce := func() error {
+		return runBackends(func(env []string) error {
+			stdout, stderr = opOutputBuffers()
+			cmd := exec.Command("op", req.Args...)
+			cmd.Env = env
+			cmd.Stdin = bytes.NewReader(req.Stdin)
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+			runErr = cmd.Run()
+			if runErr != nil {
+				return fmt.Errorf("%s\n%s", runErr, bytes.TrimSpace(stderr.Bytes()))
+			}
+			return nil
+		})
+	}
+	// a front-end may have stored a new session in the keyring since the
+	// daemon loaded it
+	if err := runOnce(); err != nil && config.SessionKeyring && isSessionError(err) {
+		loadSession()
+		runOnce()
+	}
+	if stdout.exceeded {
+		return daemonResult{Error: errOutputTooLarge.Error()}
+	} else if runErr != nil {
+		result.Error = runErr.Error()
+	}
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+
+	// big results are not kept, the daemon lives long
+	if cacheable && result.Error == "" && len(result.Stdout) <= maxCachedResult {
+		d.mu.Lock()
+		d.cache[key] = cacheEntry{result: result, fetched: time.Now(), expires: time.Now().Add(d.ttl)}
+		d.mu.Unlock()
+	}
+	return result
+}
+
+// serve handles a single front-end connection
+func (d *daemon) serve(conn net.Conn) {
+	defer conn.Close()
+	// never answer other users, the cache holds secrets
+	if checkPeer(conn) != nil {
+		return
+	}
+	var req daemonRequest
+	if err := json.NewDecoder(io.LimitReader(conn, 2*maxOpOutput)).Decode(&req); err != nil {
+		return
+	}
+	if req.Shutdown {
+		d.listener.Close()
+		return
+	}
+	json.NewEncoder(conn).Encode(d.run(req))
+}
+
+// runDaemon listens on the daemon socket until no request arrived for the
+// idle timeout
+func runDaemon() error {
+	p := daemonSocketPath()
+	if p == "" {
+		return fmt.Errorf("no cache directory for the daemon socket")
+	}
+	if err := ensurePrivateDir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	// only one daemon per socket, remove stale sockets of crashed daemons
+	if conn, err := net.Dial("unix", p); err == nil {
+		conn.Close()
+		return fmt.Errorf("daemon is already running")
+	}
+	os.Remove(p)
+
+	listener, err := net.Listen("unix", p)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	if err = os.Chmod(p, 0o600); err != nil {
+		return err
+	}
+
+	d := &daemon{cache: make(map[string]cacheEntry), ttl: defaultDaemonCacheTTL, listener: listener}
+	if config.DaemonCacheTTL != 0 {
+		d.ttl = time.Duration(config.DaemonCacheTTL)
+	}
+	idle := defaultDaemonIdleTimeout
+	if config.DaemonIdleTimeout != 0 {
+		idle = time.Duration(config.DaemonIdleTimeout)
+	}
+	timer := time.AfterFunc(idle, func() { listener.Close() })
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// the listener is closed after the idle timeout
+			return nil
+		}
+		timer.Reset(idle)
+		go d.serve(conn)
+	}
+}
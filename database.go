@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// databaseCategory is the category of Database items as returned by op
+const databaseCategory = "DATABASE"
+
+// databaseURLs returns the server of a Database item as website entries, so
+// it is matched like the website of a Login item. With a port the server is
+// also matched with that port, e.g. for git servers on a non-default port
+func databaseURLs(fields OpItemList) []OpURL {
+	server := fields.GetField("hostname")
+	if server == "" {
+		server = fields.GetField("server")
+	}
+	if server == "" {
+		return nil
+	}
+	urls := []OpURL{{Label: "server", Href: server}}
+	if port := fields.GetField("port"); port != "" && !strings.Contains(urlHost(server), ":") {
+		urls = append(urls, OpURL{Label: "server", Href: urlHost(server) + ":" + port})
+	}
+	return urls
+}
+
+// DatabaseURLs are the website entries derived from a Database item, cached
+// in the state until the item is edited
+type DatabaseURLs struct {
+	UpdatedAt time.Time `json:"updatedAt"`
+	URLs      []OpURL   `json:"urls,omitempty"`
+}
+
+// addDatabaseURLs adds the server of the Database items to their website
+// entries, "op item list" does not return fields, so only items edited since
+// their entries were cached are read
+func addDatabaseURLs(items []OpListItem) {
+	cached := loadState().DatabaseURLs
+	fetched := make(map[string]DatabaseURLs)
+	for i, item := range items {
+		if item.Category != databaseCategory {
+			continue
+		}
+		entry, ok := cached[item.ID]
+		if !ok || !entry.UpdatedAt.Equal(item.UpdatedAt) {
+			full, err := opGetItem(ItemRef{Vault: item.Vault.ID, Item: item.ID})
+			if err != nil {
+				continue
+			}
+			entry = DatabaseURLs{UpdatedAt: item.UpdatedAt, URLs: databaseURLs(full.Fields)}
+			fetched[item.ID] = entry
+		}
+		items[i].URLs = append(items[i].URLs, entry.URLs...)
+	}
+	if len(fetched) > 0 {
+		updateState(func(state *State) {
+			if state.DatabaseURLs == nil {
+				state.DatabaseURLs = make(map[string]DatabaseURLs)
+			}
+			for id, entry := range fetched {
+				state.DatabaseURLs[id] = entry
+			}
+		})
+	}
+}
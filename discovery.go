@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultDiscoveryTTL is used when the config does not set discoveryTTL
+const defaultDiscoveryTTL = 10 * time.Minute
+
+// Duration is a time.Duration read from strings like "1h" in the config file
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON formats the duration as string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Discovery is the cached result of the vault discovery of an account
+type Discovery struct {
+	Fetched time.Time `json:"fetched"`
+	Vaults  []OpVault `json:"vaults"`
+}
+
+// opListVaults runs "op vault list --format json"
+func opListVaults() ([]OpVault, error) {
+	opVaultRaw, err := runOp(buildOpCommand("vault", "list", "--format", "json"))
+	if err != nil {
+		return nil, fmt.Errorf("opVaultList failed with %s", err)
+	}
+
+	var vaults []OpVault
+	if err = json.Unmarshal(opVaultRaw, &vaults); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	return vaults, nil
+}
+
+// discoverVaults returns the vaults of the account, the result is cached in
+// the state for discoveryTTL so that not every git operation pays for an
+// additional op call
+func discoverVaults() ([]OpVault, error) {
+	ttl := defaultDiscoveryTTL
+	if config.DiscoveryTTL != 0 {
+		ttl = time.Duration(config.DiscoveryTTL)
+	}
+
+	state := loadState()
+	key := strings.Join(opFlags, " ")
+	if cached, ok := state.Discovery[key]; ok && time.Since(cached.Fetched) < ttl {
+		return cached.Vaults, nil
+	}
+
+	vaults, err := opListVaults()
+	if err != nil {
+		return nil, err
+	}
+	updateState(func(state *State) {
+		if state.Discovery == nil {
+			state.Discovery = make(map[string]Discovery)
+		}
+		state.Discovery[key] = Discovery{Fetched: time.Now(), Vaults: vaults}
+	})
+	return vaults, nil
+}
+
+// searchVaults returns the ids of all vaults of the account except the given
+// one, which has been searched already
+func searchVaults(searched string) []string {
+	vaults, err := discoverVaults()
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, v := range vaults {
+		if v.ID == searched || (searched != "" && v.Name == searched) {
+			continue
+		}
+		ids = append(ids, v.ID)
+	}
+	return ids
+}
+
+// levenshtein returns the edit distance of two strings
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// validateVault checks a vault name or id against the vaults of the account
+// and returns the id of the vault. Unknown vaults result in a precise error
+// with the closest vault name as suggestion
+func validateVault(v string) (string, error) {
+	vaults, err := discoverVaults()
+	if err != nil {
+		// op itself reports a generic failure later
+		return v, nil
+	}
+	for _, candidate := range vaults {
+		if candidate.ID == v || candidate.Name == v {
+			return candidate.ID, nil
+		}
+	}
+	for _, candidate := range vaults {
+		if strings.EqualFold(candidate.Name, v) {
+			return candidate.ID, nil
+		}
+	}
+
+	best, distance := "", -1
+	for _, candidate := range vaults {
+		if d := levenshtein(strings.ToLower(candidate.Name), strings.ToLower(v)); distance < 0 || d < distance {
+			best, distance = candidate.Name, d
+		}
+	}
+	if best != "" && distance <= len(v)/2+1 {
+		return v, fmt.Errorf("vault %q not found; did you mean %q?", v, best)
+	}
+	return v, fmt.Errorf("vault %q not found", v)
+}
+
+// OpAccount is an entry of the output of "op account list --format json"
+type OpAccount struct {
+	URL         string `json:"url"`
+	Email       string `json:"email"`
+	UserUUID    string `json:"user_uuid"`
+	AccountUUID string `json:"account_uuid"`
+}
+
+// AccountDiscovery is the cached list of accounts
+type AccountDiscovery struct {
+	Fetched  time.Time   `json:"fetched"`
+	Accounts []OpAccount `json:"accounts"`
+}
+
+// discoverAccounts returns the accounts configured in op, cached like the
+// vaults
+func discoverAccounts() ([]OpAccount, error) {
+	ttl := defaultDiscoveryTTL
+	if config.DiscoveryTTL != 0 {
+		ttl = time.Duration(config.DiscoveryTTL)
+	}
+
+	state := loadState()
+	if time.Since(state.Accounts.Fetched) < ttl {
+		return state.Accounts.Accounts, nil
+	}
+
+	raw, err := runOp(exec.Command("op", "account", "list", "--format", "json"))
+	if err != nil {
+		return nil, fmt.Errorf("opAccountList failed with %s", err)
+	}
+	var accounts []OpAccount
+	if err = json.Unmarshal(raw, &accounts); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	updateState(func(state *State) {
+		state.Accounts = AccountDiscovery{Fetched: time.Now(), Accounts: accounts}
+	})
+	return accounts, nil
+}
+
+// PrintVaults prints the id and name of all vaults of the account
+func PrintVaults() error {
+	vaults, err := discoverVaults()
+	if err != nil {
+		return err
+	}
+	for _, v := range vaults {
+		fmt.Printf("%s\t%s\n", v.ID, v.Name)
+	}
+	return nil
+}
+
+// PrintAccounts prints the url, email and id of all accounts
+func PrintAccounts() error {
+	accounts, err := discoverAccounts()
+	if err != nil {
+		return err
+	}
+	for _, a := range accounts {
+		fmt.Printf("%s\t%s\t%s\n", a.URL, a.Email, a.AccountUUID)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+)
+
+// doctorCheck prints a single line of the doctor report
+func doctorCheck(name string, format string, args ...any) {
+	fmt.Printf("%s %s\n", bold(fmt.Sprintf("%-14s", name+":")), fmt.Sprintf(format, args...))
+}
+
+// Doctor prints the setup and the state of the helper
+func Doctor() {
+	doctorCheck("helper", "%s", getVersion())
+
+	if v, err := opVersion(); err != nil {
+		doctorCheck("op", "%s (%s)", red("not found"), err)
+	} else if compareVersions(v, minOpVersion) < 0 {
+		doctorCheck("op", "%s (%s, %s or later required)", v, yellow("unsupported"), minOpVersion)
+	} else {
+		doctorCheck("op", "%s", v)
+	}
+	if opUnlocked() {
+		doctorCheck("signed in", "%s", green("yes"))
+	} else {
+		doctorCheck("signed in", "%s (run `op signin` or enable CLI integration in the 1Password app)", red("no"))
+	}
+
+	if isRemoteConfig(configPath) {
+		doctorCheck("config", "%s (copy in %s)", configPath, remoteConfigCachePath(configPath))
+	} else if _, err := os.Stat(configPath); err == nil {
+		doctorCheck("config", "%s", configPath)
+	} else {
+		doctorCheck("config", "%s (not found, using defaults)", configPath)
+	}
+	doctorCheck("state", "%s", statePath())
+	if notes := competingHelpers(); len(notes) > 0 {
+		for _, note := range notes {
+			doctorCheck("helpers", "%s", yellow(note))
+		}
+	} else {
+		doctorCheck("helpers", "%s", green("no competing credential helpers"))
+	}
+
+	if !config.Daemon {
+		doctorCheck("daemon", "disabled")
+	} else if conn, err := net.Dial("unix", daemonSocketPath()); err == nil {
+		conn.Close()
+		doctorCheck("daemon", "running (%s)", daemonSocketPath())
+	} else {
+		doctorCheck("daemon", "not running")
+	}
+
+	v, source := effectiveOpCache()
+	doctorCheck("op cache", "%s (%s)", v, source)
+
+	health := loadState().Backends
+	for _, backend := range config.Backends {
+		h := health[backend.Name]
+		switch {
+		case h.failing():
+			doctorCheck("backend", "%s %s since %s (%s)", backend.Name, red("failing"), h.LastFailure.Format(time.RFC3339), h.LastError)
+		case !h.LastServed.IsZero():
+			doctorCheck("backend", "%s %s, last served %s", backend.Name, green("ok"), h.LastServed.Format(time.RFC3339))
+		default:
+			doctorCheck("backend", "%s not used yet", backend.Name)
+		}
+	}
+
+	rate := loadState().RateLimit
+	switch {
+	case rate.Last.IsZero():
+		doctorCheck("rate limit", "never hit")
+	case time.Now().Before(rate.Until):
+		doctorCheck("rate limit", "%s until %s (%d consecutive hits)", yellow("pacing requests"), rate.Until.Format(time.RFC3339), rate.Hits)
+	default:
+		doctorCheck("rate limit", "last hit %s", rate.Last.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// documentFormats lists the formats of documents holding credentials
+var documentFormats = []string{"netrc", "credential", "token"}
+
+// opGetDocument downloads the content of a Document item
+func opGetDocument(ref ItemRef) ([]byte, error) {
+	if err := checkItemArg(ref.Item); err != nil {
+		return nil, err
+	}
+	args := []string{ref.Item}
+	if ref.Vault != "" {
+		args = append([]string{"--vault=" + ref.Vault}, args...)
+	}
+	output, err := runOp(buildOpCommand("document", "get", args...))
+	if err != nil {
+		return nil, fmt.Errorf("opDocumentGet failed with %s", err)
+	}
+	return output, nil
+}
+
+// documentFormat guesses the format of a document: netrc files start with
+// machine or default, credential files are in the format of git credential
+// and everything else is a token
+func documentFormat(data []byte) string {
+	fields := strings.Fields(string(data))
+	if len(fields) > 0 && (fields[0] == "machine" || fields[0] == "default") {
+		return "netrc"
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "password=") {
+			return "credential"
+		}
+	}
+	return "token"
+}
+
+// parseNetrc returns the login and password of the machine, the default
+// entry is used when the machine is not listed
+func parseNetrc(data []byte, machine string) (string, string, bool) {
+	var entries []map[string]string
+	words := strings.Fields(string(data))
+	for i := 0; i < len(words); i++ {
+		switch token := words[i]; token {
+		case "default":
+			entries = append(entries, map[string]string{"default": "1"})
+		case "machine", "login", "password", "account", "macdef":
+			if i+1 == len(words) {
+				break
+			}
+			if token == "machine" {
+				entries = append(entries, make(map[string]string))
+			}
+			if len(entries) > 0 {
+				entries[len(entries)-1][token] = words[i+1]
+			}
+			i++
+		}
+	}
+	hostname, _, _ := strings.Cut(machine, ":")
+	for _, name := range []string{machine, hostname} {
+		for _, entry := range entries {
+			if entry["machine"] == name {
+				return entry["login"], entry["password"], true
+			}
+		}
+	}
+	for _, entry := range entries {
+		if entry["default"] != "" {
+			return entry["login"], entry["password"], true
+		}
+	}
+	return "", "", false
+}
+
+// parseDocument extracts the username and password for the host from the
+// content of a document
+func parseDocument(data []byte, format string, host string) (string, string, error) {
+	if format == "" {
+		format = documentFormat(data)
+	}
+	switch format {
+	case "netrc":
+		username, password, ok := parseNetrc(data, host)
+		if !ok {
+			return "", "", fmt.Errorf("the document has no netrc entry for %s", host)
+		}
+		return username, password, nil
+	case "credential":
+		attrs := make(map[string]string)
+		for _, line := range strings.Split(string(data), "\n") {
+			if key, value, ok := strings.Cut(strings.TrimSuffix(line, "\r"), "="); ok {
+				attrs[key] = value
+			}
+		}
+		return attrs["username"], attrs["password"], nil
+	}
+	return "", strings.TrimSpace(string(data)), nil
+}
+
+// documentItem serves the credential of a host configured with a document
+// instead of a Login item
+func documentItem(hc HostConfig, host string) (*OpFullItem, error) {
+	ref := parseItemRef(hc.Document)
+	if ref.Vault == "" {
+		ref.Vault = vault
+	}
+	data, err := opGetDocument(ref)
+	if err != nil {
+		return nil, err
+	}
+	username, password, err := parseDocument(data, hc.DocumentFormat, host)
+	if err != nil {
+		return nil, err
+	}
+	return credentialItem(username, password), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// baseEnv lists the variables op needs to find its configuration and the
+// 1Password app, everything else is not passed to op unless configured
+var baseEnv = []string{"HOME", "PATH", "USER", "LOGNAME", "TMPDIR", "XDG_CONFIG_HOME", "XDG_RUNTIME_DIR", "TERM"}
+
+// windowsEnv lists the variables needed on Windows in addition to baseEnv
+var windowsEnv = []string{"USERPROFILE", "APPDATA", "LOCALAPPDATA", "PROGRAMDATA", "SYSTEMROOT", "SYSTEMDRIVE", "TEMP", "TMP", "COMSPEC", "PATHEXT"}
+
+// opEnv builds the environment of op child processes: OP_* variables, the
+// variables of baseEnv, the variables listed in passEnv and the variables
+// set in env of the config file
+func opEnv() []string {
+	names := append(append([]string{}, baseEnv...), config.PassEnv...)
+	if runtime.GOOS == "windows" {
+		names = append(names, windowsEnv...)
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "OP_") || containsEnv(names, name) {
+			env = append(env, kv)
+		}
+	}
+	for name, value := range config.Env {
+		env = append(env, name+"="+value)
+	}
+	if session := currentSessionEnv(); session != "" {
+		env = append(env, session)
+	}
+	// an OP_CACHE of the user is kept unless opCache is configured
+	if v, auto := opCache(); v != "" && (!auto || !hasEnv(env, "OP_CACHE")) {
+		env = append(env, "OP_CACHE="+v)
+	}
+	return env
+}
+
+// opCache returns the value of OP_CACHE for op and whether it was chosen
+// automatically: the opCache setting, otherwise the cache of op is enabled
+// where op supports it and disabled in CI, where the cache process of op
+// would outlive the job, and for service accounts and Connect, which op
+// does not cache
+func opCache() (string, bool) {
+	if config.OpCache != nil {
+		return strconv.FormatBool(*config.OpCache), false
+	}
+	if runtime.GOOS == "windows" {
+		return "", true
+	}
+	if ciMode || os.Getenv("OP_SERVICE_ACCOUNT_TOKEN") != "" || os.Getenv("OP_CONNECT_HOST") != "" {
+		return "false", true
+	}
+	return "true", true
+}
+
+// effectiveOpCache returns the OP_CACHE op runs with and where it comes
+// from, for doctor
+func effectiveOpCache() (string, string) {
+	v, auto := opCache()
+	for _, kv := range opEnv() {
+		if name, value, _ := strings.Cut(kv, "="); containsEnv([]string{"OP_CACHE"}, name) {
+			switch {
+			case value != v:
+				return value, "OP_CACHE"
+			case auto:
+				return value, "automatic"
+			}
+			return value, "opCache"
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "false", "not supported by op on Windows"
+	}
+	return "true", "default of op"
+}
+
+// hasEnv reports whether the environment sets the variable
+func hasEnv(env []string, name string) bool {
+	for _, kv := range env {
+		if n, _, _ := strings.Cut(kv, "="); containsEnv([]string{name}, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsEnv reports whether name is in names, environment variable names
+// are case insensitive on Windows
+func containsEnv(names []string, name string) bool {
+	for _, n := range names {
+		if n == name || (runtime.GOOS == "windows" && strings.EqualFold(n, name)) {
+			return true
+		}
+	}
+	return false
+}
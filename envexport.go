@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// envNamePattern matches valid environment variable names
+	envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// envNameInvalid matches characters replaced in derived names
+	envNameInvalid = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// envSpec splits "<host|op-ref>[:NAME]" into the secret and the variable
+// name, without a name it is derived from the host or the field
+func envSpec(spec string) (string, string) {
+	if i := strings.LastIndex(spec, ":"); i > 0 && envNamePattern.MatchString(spec[i+1:]) {
+		return spec[:i], spec[i+1:]
+	}
+	name := spec
+	if rest, ok := strings.CutPrefix(spec, "op://"); ok {
+		parts := strings.Split(rest, "/")
+		name = parts[len(parts)-1]
+		if len(parts) < 3 {
+			name += "_password"
+		}
+	} else {
+		name += "_token"
+	}
+	name = strings.ToUpper(envNameInvalid.ReplaceAllString(name, "_"))
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return spec, name
+}
+
+// envSecret resolves a host or secret reference to its secret, references
+// to a field are read with "op read", all others return the password
+func envSecret(secret string) (string, error) {
+	if rest, ok := strings.CutPrefix(secret, "op://"); ok && strings.Count(rest, "/") >= 2 {
+		output, err := runOp(buildOpCommand("read", secret, "--no-newline"))
+		if err != nil {
+			return "", fmt.Errorf("op read failed with %s", friendlyError(err))
+		}
+		return string(output), nil
+	}
+	var item *OpFullItem
+	var err error
+	var hc HostConfig
+	if strings.HasPrefix(secret, "op://") {
+		item, err = opGetItem(parseItemRef(secret))
+	} else {
+		if err = checkHost(secret); err != nil {
+			return "", err
+		}
+		hc = hostConfig(secret)
+		_, item, err = lookupRecentItem(vault, resolveHost(secret), secret, hc.Username)
+	}
+	if err != nil {
+		return "", friendlyError(err)
+	}
+	password, _ := item.Fields.resolvePassword(hc)
+	return password, nil
+}
+
+// dotenvQuote quotes a value for dotenv files
+func dotenvQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// PrintEnv prints the secrets of hosts or secret references as environment
+// variables, e.g. for eval in a .envrc
+func PrintEnv(args []string) error {
+	flags := flag.NewFlagSet("env", flag.ExitOnError)
+	format := flags.String("format", "export", "Output format: export or dotenv")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: git credential-1password env [--format export|dotenv] <host|op-ref>[:NAME]...")
+	}
+	if *format != "export" && *format != "dotenv" {
+		return fmt.Errorf("invalid format %q, expected export or dotenv", *format)
+	}
+
+	var lines []string
+	for _, spec := range flags.Args() {
+		secret, name := envSpec(spec)
+		value, err := envSecret(secret)
+		if err != nil {
+			return err
+		}
+		maskSecret(value)
+		if *format == "dotenv" {
+			lines = append(lines, name+"="+dotenvQuote(value))
+		} else {
+			lines = append(lines, "export "+name+"="+shellQuote(value))
+		}
+	}
+	// print nothing unless all secrets were resolved
+	fmt.Println(strings.Join(lines, "\n"))
+	return nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// HelperError is a classified failure with a short message and a concrete
+// next step for the user
+type HelperError struct {
+	Code    string
+	Message string
+	Hint    string
+	// Retryable is set for transient failures that may succeed on a retry
+	Retryable bool
+	Err       error
+}
+
+// Error returns the message and hint, the raw op output is only included
+// with --verbose
+func (e *HelperError) Error() string {
+	msg := e.Message
+	if e.Hint != "" {
+		msg += "\nhint: " + e.Hint
+	}
+	if verbose && e.Err != nil {
+		msg += "\n" + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *HelperError) Unwrap() error {
+	return e.Err
+}
+
+// errorClass maps patterns of op output to a classified error
+type errorClass struct {
+	patterns  []string
+	code      string
+	message   string
+	hint      string
+	retryable bool
+}
+
+// errorClasses lists the common failures of op, the first match wins
+var errorClasses = []errorClass{
+	{
+		patterns: []string{"executable file not found"},
+		code:     "op_missing",
+		message:  "the 1Password CLI (op) was not found",
+		hint:     "install it from https://developer.1password.com/docs/cli/get-started/ and make sure it is in your PATH",
+	},
+	{
+		patterns:  []string{"no such host", "dial tcp", "connection refused", "network is unreachable", "i/o timeout", "could not connect", "failed to connect", "service unavailable"},
+		code:      "unreachable",
+		message:   "1Password could not be reached",
+		hint:      "check your network connection or the 1Password status page",
+		retryable: true,
+	},
+	{
+		patterns:  []string{"rate limit", "too many requests"},
+		code:      "rate_limited",
+		message:   "the 1Password rate limit was exceeded",
+		hint:      "wait a moment and retry, `git credential-1password doctor` shows the rate limit state",
+		retryable: true,
+	},
+	{
+		patterns:  []string{"authorization prompt dismissed", "authorization timeout", "authorization denied", "biometric", "user cancelled", "user canceled"},
+		code:      "auth_denied",
+		message:   "1Password did not authorize the request",
+		hint:      "approve the prompt of the 1Password app and run git again",
+		retryable: true,
+	},
+	{
+		patterns: []string{"invalid bearer token", "connect token", "op_connect_token"},
+		code:     "connect_token",
+		message:  "the 1Password Connect token is invalid or expired",
+		hint:     "renew OP_CONNECT_TOKEN or unset OP_CONNECT_HOST to use the 1Password app",
+	},
+	{
+		patterns: []string{"app is locked", "1password is locked", "account is locked"},
+		code:     "locked",
+		message:  "1Password is locked",
+		hint:     "unlock the 1Password app and run git again",
+	},
+	{
+		patterns: []string{"not currently signed in", "session expired", "no accounts configured", "account is not signed in", "invalid session"},
+		code:     "not_signed_in",
+		message:  "the 1Password CLI is not signed in",
+		hint:     "run `op signin` or enable CLI integration in the 1Password app (Settings > Developer)",
+	},
+	{
+		patterns: []string{"permission denied", "don't have permission", "do not have permission", "not allowed", "forbidden", "read-only"},
+		code:     "permission_denied",
+		message:  "the vault is read-only for you",
+		hint:     "set writableVault in the config file or use --vault with a vault you can write to",
+	},
+	{
+		patterns: []string{"isn't a vault", "vault not found", "no vault found"},
+		code:     "vault_not_found",
+		message:  "the vault was not found",
+		hint:     "check --vault, `op vault list` shows the available vaults",
+	},
+	{
+		patterns: []string{"more than one item matches"},
+		code:     "item_ambiguous",
+		message:  "more than one item matches",
+		hint:     "remove or rename the duplicates, `git credential-1password conflicts` lists them",
+	},
+	{
+		patterns: []string{"isn't an item", "item not found", "no item found"},
+		code:     "item_not_found",
+		message:  "no item was found for the host",
+		hint:     "create a Login item named like the host or let git store the credential",
+	},
+}
+
+// opError is the machine-readable error op passes on from the 1Password
+// servers or Connect, e.g. {"status": 404, "message": "..."}
+type opError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// opErrorLine matches the log line op prints for errors, the date and time
+// are stripped to get the message
+var opErrorLine = regexp.MustCompile(`(?m)^\[ERROR\] \d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} (.*)$`)
+
+// parseOpError extracts the structured error from the output of op, the
+// status is reliable across op versions and locales, unlike the message
+func parseOpError(output string) (opError, bool) {
+	message := output
+	if m := opErrorLine.FindStringSubmatch(output); m != nil {
+		message = m[1]
+	}
+	if i := strings.Index(message, "{"); i >= 0 {
+		var e opError
+		if json.NewDecoder(strings.NewReader(message[i:])).Decode(&e) == nil && e.Status != 0 {
+			return e, true
+		}
+	}
+	return opError{}, false
+}
+
+// statusCode maps the status of a structured op error to the code of its
+// error class
+func statusCode(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		if os.Getenv("OP_CONNECT_HOST") != "" || config.Env["OP_CONNECT_HOST"] != "" {
+			return "connect_token"
+		}
+		return "not_signed_in"
+	case http.StatusForbidden:
+		return "permission_denied"
+	case http.StatusNotFound:
+		return "item_not_found"
+	case http.StatusConflict:
+		return "edit_conflict"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return "unreachable"
+	}
+	return ""
+}
+
+// opErrorCode returns the code of the structured error in the output of
+// op, empty if op reported none
+func opErrorCode(err error) string {
+	if e, ok := parseOpError(err.Error()); ok {
+		if e.Status == http.StatusNotFound && strings.Contains(strings.ToLower(e.Message), "vault") {
+			return "vault_not_found"
+		}
+		return statusCode(e.Status)
+	}
+	return ""
+}
+
+// classError returns the error of the class with the code
+func classError(code string, err error) error {
+	for _, class := range errorClasses {
+		if class.code == code {
+			return &HelperError{Code: class.code, Message: class.message, Hint: class.hint, Retryable: class.retryable, Err: err}
+		}
+	}
+	return err
+}
+
+// friendlyError classifies an op failure, structured errors are classified
+// by their status, other failures by their message, unknown failures are
+// returned as is
+func friendlyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var helperErr *HelperError
+	if errors.As(err, &helperErr) {
+		return err
+	}
+	if code := opErrorCode(err); code != "" {
+		return classError(code, err)
+	}
+	msg := strings.ToLower(err.Error())
+	for _, class := range errorClasses {
+		for _, pattern := range class.patterns {
+			if strings.Contains(msg, pattern) {
+				return &HelperError{Code: class.code, Message: class.message, Hint: class.hint, Retryable: class.retryable, Err: err}
+			}
+		}
+	}
+	return err
+}
+
+// ErrorEnvelope is the machine-readable form of an error for integrating
+// tools, it never contains the raw output of op
+type ErrorEnvelope struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Hint      string `json:"hint,omitempty"`
+	Retryable bool   `json:"retryable"`
+}
+
+// errorEnvelope classifies the error, unclassified errors get the code
+// "error" and a generic message, their text may be the raw output of op and
+// is only included with --verbose
+func errorEnvelope(err error) *ErrorEnvelope {
+	var helperErr *HelperError
+	if errors.As(friendlyError(err), &helperErr) {
+		return &ErrorEnvelope{Code: helperErr.Code, Message: helperErr.Message, Hint: helperErr.Hint, Retryable: helperErr.Retryable}
+	}
+	envelope := &ErrorEnvelope{Code: "error", Message: "the request failed", Hint: "run the helper with --verbose for details"}
+	if verbose {
+		envelope.Message, envelope.Hint = err.Error(), ""
+	}
+	return envelope
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultExchangeTTL is the lifetime of exchanged tokens when the host does
+// not set exchangeTTL
+const defaultExchangeTTL = time.Hour
+
+// tokenExchanges mint short-lived access tokens from the long-lived token
+// stored in the item
+var tokenExchanges = map[string]func(host string, username string, token string, ttl time.Duration) (string, error){
+	"artifactory": artifactoryToken,
+}
+
+// artifactoryToken exchanges an identity token for a short-lived access
+// token via the access API of Artifactory
+func artifactoryToken(host string, username string, token string, ttl time.Duration) (string, error) {
+	form := url.Values{
+		"expires_in": {strconv.Itoa(int(ttl.Seconds()))},
+		"scope":      {"applied-permissions/user"},
+	}
+	if username != "" {
+		form.Set("username", username)
+	}
+	req, err := http.NewRequest("POST", "https://"+host+"/access/api/v1/tokens", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("the token exchange with %s failed with %s", host, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("the token exchange with %s failed with %s", host, resp.Status)
+	}
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("the token exchange with %s returned no token", host)
+	}
+	return result.AccessToken, nil
+}
+
+// exchangeToken mints a short-lived token for the host with the exchange of
+// its settings
+func exchangeToken(hc HostConfig, host string, username string, token string) (string, error) {
+	ttl := defaultExchangeTTL
+	if hc.ExchangeTTL != 0 {
+		ttl = time.Duration(hc.ExchangeTTL)
+	}
+	return tokenExchanges[hc.Exchange](host, username, token, ttl)
+}
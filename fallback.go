@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// isUnavailable reports whether op failed because 1Password could not be
+// used at all, as opposed to a definitive answer like a missing item or a
+// denied authorization
+func isUnavailable(err error) bool {
+	var helperErr *HelperError
+	if !errors.As(friendlyError(err), &helperErr) {
+		return false
+	}
+	switch helperErr.Code {
+	case "unreachable", "op_missing", "not_signed_in", "locked":
+		return true
+	}
+	return false
+}
+
+// fallbackItem returns a credential from the offline cache or the OS
+// keychain if enabled and op failed because 1Password is unavailable
+func fallbackItem(key string, err error) *OpFullItem {
+	if !isUnavailable(err) {
+		return nil
+	}
+	if config.OfflineCache {
+		if entry, offlineErr := loadOffline(key); offlineErr == nil {
+			warnf("1Password is unavailable, using the cached credential from %s", entry.Fetched.Format(time.RFC3339))
+			return credentialItem(entry.Username, entry.Password)
+		}
+	}
+	if config.KeychainFallback {
+		if raw, keychainErr := keyringGet("credential:" + key); keychainErr == nil {
+			var entry OfflineEntry
+			if json.Unmarshal([]byte(raw), &entry) == nil && (credentialTTL == 0 || time.Since(entry.Fetched) < credentialTTL) {
+				warnf("1Password is unavailable, using the credential from the OS keychain")
+				return credentialItem(entry.Username, entry.Password)
+			}
+		}
+	}
+	return nil
+}
+
+// credentialItem wraps a username and password served without op
+func credentialItem(username string, password string) *OpFullItem {
+	return &OpFullItem{Fields: OpItemList{{Label: "username", Value: username}, {Label: "password", Value: password}}}
+}
+
+// storeKeychain writes the credential to the OS keychain, for the username
+// and as the latest credential of the host
+func storeKeychain(host string, username string, password string) {
+	raw, err := json.Marshal(OfflineEntry{Username: username, Password: password, Fetched: time.Now()})
+	if err != nil {
+		return
+	}
+	for _, key := range []string{stateKey(host, username), stateKey(host, "")} {
+		if err := keyringSet("credential:"+key, string(raw)); err != nil {
+			warnf("storing the credential in the OS keychain failed with %s", err)
+			return
+		}
+	}
+}
+
+// eraseKeychain removes the credential from the OS keychain
+func eraseKeychain(host string, username string) {
+	keyringDelete("credential:" + stateKey(host, username))
+	keyringDelete("credential:" + stateKey(host, ""))
+}
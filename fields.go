@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultPasswordFields is the order in which fields are tried as the
+// password: the password of Login items, the credential of API Credential
+// items and a field named token
+var defaultPasswordFields = []string{"password", "credential", "token"}
+
+// defaultFieldLabels are translated labels of the username and password
+// fields, items created by 1Password have a purpose or a stable id, these
+// are for fields that were added by hand in a non-English install
+var defaultFieldLabels = map[string][]string{
+	"username": {"Benutzername", "Nom d'utilisateur", "Nombre de usuario", "Nome utente", "Gebruikersnaam", "Nazwa użytkownika", "Имя пользователя", "ユーザー名", "用户名"},
+	"password": {"Passwort", "Mot de passe", "Contraseña", "Wachtwoord", "Hasło", "Пароль", "パスワード", "密码"},
+}
+
+// fieldLabels returns the labels that name the field in other languages,
+// the fieldLabels of the config extend the built-in table
+func fieldLabels(name string) []string {
+	return append(config.FieldLabels[name], defaultFieldLabels[name]...)
+}
+
+// checkFieldLabels validates the fieldLabels table
+func checkFieldLabels(labels map[string][]string) error {
+	for name, aliases := range labels {
+		if name == "" {
+			return fmt.Errorf("empty field name in fieldLabels")
+		}
+		for _, alias := range aliases {
+			if strings.TrimSpace(alias) == "" {
+				return fmt.Errorf("empty label for field %q in fieldLabels", name)
+			}
+		}
+	}
+	return nil
+}
+
+// passwordFields returns the resolution order of the password field for the
+// host settings, falling back to the global order and the default
+func passwordFields(hc HostConfig) []string {
+	if len(hc.PasswordFields) > 0 {
+		return hc.PasswordFields
+	}
+	if len(config.PasswordFields) > 0 {
+		return config.PasswordFields
+	}
+	return defaultPasswordFields
+}
+
+// checkFieldSpec validates an entry of passwordFields
+func checkFieldSpec(spec string) error {
+	if kind, name, ok := strings.Cut(spec, ":"); ok {
+		switch kind {
+		case "purpose", "id", "label":
+		default:
+			return fmt.Errorf("invalid field %q, expected purpose:, id: or label: as prefix", spec)
+		}
+		spec = name
+	}
+	if spec == "" {
+		return fmt.Errorf("empty field name in passwordFields")
+	}
+	return nil
+}
+
+// field returns the value of the first non-empty field matching the spec,
+// "purpose:PASSWORD", "id:credential" and "label:token" match exactly,
+// names without prefix are looked up like GetField does
+func (i OpItemList) field(spec string) string {
+	kind, name, ok := strings.Cut(spec, ":")
+	if !ok {
+		return i.GetField(spec)
+	}
+	for _, f := range i {
+		switch {
+		case kind == "purpose" && strings.EqualFold(f.Purpose, name),
+			kind == "id" && f.ID == name,
+			kind == "label" && f.Label == name:
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// resolvePassword returns the password of the item and the field spec it
+// was found with, the first field in the resolution order with a value wins
+func (i OpItemList) resolvePassword(hc HostConfig) (string, string) {
+	for _, spec := range passwordFields(hc) {
+		if value := i.field(spec); value != "" {
+			return value, spec
+		}
+	}
+	return "", ""
+}
+
+// passkeyOnly reports whether the item signs in with a passkey and has no
+// password, such items can not be used by git
+func (i *OpFullItem) passkeyOnly(hc HostConfig) bool {
+	if i == nil {
+		return false
+	}
+	if password, _ := i.Fields.resolvePassword(hc); password != "" {
+		return false
+	}
+	for _, f := range i.Fields {
+		if f.Type == "PASSKEY" || strings.EqualFold(f.ID, "passkey") || strings.EqualFold(f.Label, "passkey") {
+			return true
+		}
+	}
+	return false
+}
+
+// passkeyError explains why a passkey-only item can not be used
+func passkeyError(item *OpFullItem) error {
+	return &HelperError{
+		Code:    "passkey_only",
+		Message: fmt.Sprintf("item %q only has a passkey, git can not sign in with it", item.Title),
+		Hint:    "create a personal access token or app password and store it in the item, or set skipPasskeyItems to use the next matching item",
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// firstRunSummary returns what the helper does to the vaults and where it
+// keeps its data, in a few lines
+func firstRunSummary() []string {
+	target := "the default vault of op (Private or Personal)"
+	if vault != "" {
+		target = fmt.Sprintf("the vault %q", vault)
+	}
+	lines := []string{
+		"stores the credentials git approves in 1Password, new items are created in " + target,
+	}
+	if config.WritableVault != "" {
+		lines = append(lines, fmt.Sprintf("creates the items of read-only vaults in the vault %q", config.WritableVault))
+	}
+	lines = append(lines, "deletes the item of a credential git rejects, e.g. after a failed login")
+	lines = append(lines, "prints warnings and errors to the stderr of git, local state is kept in "+filepath.Dir(statePath()))
+	if config.Tracing != nil && config.Tracing.Endpoint != "" {
+		lines = append(lines, "sends traces without secrets to "+config.Tracing.Endpoint)
+	}
+	return lines
+}
+
+// maybeShowFirstRun prints the summary on the first interactive use and
+// records that it was shown, so users who inherited a dotfiles setup are not
+// surprised by items appearing in their vaults
+func maybeShowFirstRun() {
+	if quiet || ciMode || sandboxVault != "" || !isTerminal(os.Stderr) {
+		return
+	}
+	if !loadState().Acknowledged.IsZero() {
+		return
+	}
+	updateState(func(state *State) { state.Acknowledged = time.Now() })
+	fmt.Fprintln(os.Stderr, "git-credential-1password is now used by git, it")
+	for _, line := range firstRunSummary() {
+		fmt.Fprintf(os.Stderr, "  - %s\n", line)
+	}
+	fmt.Fprintln(os.Stderr, "This is shown once, `git credential-1password doctor` shows the current setup.")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// gitConfigSection is the prefix of the settings of the helper in the git
+// config, e.g. credential.1password.vault
+const gitConfigSection = "credential.1password."
+
+// gitConfigKeys are the settings that can be overridden for a single git
+// command, they correspond to the options of the same name
+var gitConfigKeys = []string{"account", "vault", "prefix", "config"}
+
+// gitConfigSettings are settings read from the git config directly, they
+// have no option
+var gitConfigSettings = []string{"item"}
+
+// pinnedItem is the item the repository is pinned to with
+// credential.1password.item, it is used regardless of the host
+var pinnedItem string
+
+// gitConfigGet returns the value of a setting from the git config of the
+// repository git runs the helper in, including its global config and -c
+func gitConfigGet(key string) string {
+	output, err := exec.Command("git", "config", "--get", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(output), "\n")
+}
+
+// pinnedItemRef returns the reference of the pinned item, titles are
+// looked up in the configured vault
+func pinnedItemRef() ItemRef {
+	ref := parseItemRef(pinnedItem)
+	if ref.Vault == "" {
+		ref.Vault = vault
+	}
+	return ref
+}
+
+// splitQuoted reads a single quoted word like git writes it with sq_quote
+// and returns it with the rest, a quote within the word is closed, escaped
+// with a backslash and reopened
+func splitQuoted(s string) (string, string, error) {
+	var word strings.Builder
+	for {
+		if !strings.HasPrefix(s, "'") {
+			return "", "", fmt.Errorf("expected a quoted word at %q", s)
+		}
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quote in %q", s)
+		}
+		word.WriteString(s[1 : end+1])
+		s = s[end+2:]
+		rest, ok := strings.CutPrefix(s, `\'`)
+		if !ok {
+			return word.String(), s, nil
+		}
+		word.WriteByte('\'')
+		s = rest
+	}
+}
+
+// parseConfigParameters parses GIT_CONFIG_PARAMETERS, the settings of
+// "git -c", as entries 'key'='value' or 'key=value' of older versions
+func parseConfigParameters(s string) ([][2]string, error) {
+	var entries [][2]string
+	for s = strings.TrimLeft(s, " "); s != ""; s = strings.TrimLeft(s, " ") {
+		key, rest, err := splitQuoted(s)
+		if err != nil {
+			return nil, err
+		}
+		value := ""
+		if after, ok := strings.CutPrefix(rest, "="); ok {
+			if value, rest, err = splitQuoted(after); err != nil {
+				return nil, err
+			}
+		} else {
+			key, value, _ = strings.Cut(key, "=")
+		}
+		entries = append(entries, [2]string{key, value})
+		s = rest
+	}
+	return entries, nil
+}
+
+// gitConfigOverrides returns the settings of the helper git passes for a
+// single command, set with "git -c credential.1password.vault=Work ..." or
+// GIT_CONFIG_COUNT, GIT_CONFIG_KEY_<n> and GIT_CONFIG_VALUE_<n>, later
+// entries win like they do in git
+func gitConfigOverrides() map[string]string {
+	entries, err := parseConfigParameters(os.Getenv("GIT_CONFIG_PARAMETERS"))
+	if err != nil {
+		warnf("ignoring GIT_CONFIG_PARAMETERS: %s", err)
+		entries = nil
+	}
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	for i := 0; i < count; i++ {
+		n := strconv.Itoa(i)
+		entries = append(entries, [2]string{os.Getenv("GIT_CONFIG_KEY_" + n), os.Getenv("GIT_CONFIG_VALUE_" + n)})
+	}
+
+	overrides := make(map[string]string)
+	for _, entry := range entries {
+		// git lowercases the section and the name of a setting
+		key := strings.ToLower(entry[0])
+		name, ok := strings.CutPrefix(key, gitConfigSection)
+		if !ok {
+			continue
+		}
+		if contains(gitConfigSettings, name) {
+			continue
+		}
+		if !contains(gitConfigKeys, name) {
+			warnf("ignoring unknown setting %s, expected one of %s", entry[0], strings.Join(gitConfigKeys, ", "))
+			continue
+		}
+		overrides[name] = entry[1]
+	}
+	return overrides
+}
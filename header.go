@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PrintHeader prints the custom header of a host with the credential from
+// 1Password, for use with http.extraHeader
+func PrintHeader(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: git credential-1password header <host>")
+	}
+	host := args[0]
+	if err := checkHost(host); err != nil {
+		return err
+	}
+	header := hostConfig(host).Header
+	if header == "" {
+		return fmt.Errorf("no header configured for %s, set header in its settings", host)
+	}
+	credential, err := resolveCredential(Attributes{{"protocol", "https"}, {"host", host}})
+	if err != nil {
+		return err
+	}
+	if strings.ContainsAny(credential.Password, "\r\n") {
+		return fmt.Errorf("the credential of %s contains a newline, which is not allowed in a header", host)
+	}
+	fmt.Printf("%s: %s\n", header, credential.Password)
+	return nil
+}
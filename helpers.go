@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// configuredHelper is a credential.helper entry of the git config
+type configuredHelper struct {
+	// Origin is the file the entry comes from, e.g. file:/home/alice/.gitconfig
+	Origin string
+	Value  string
+}
+
+// isOwnHelper reports whether the helper entry runs this helper, git runs
+// "1password" as git-credential-1password, a path or a shell command with a
+// leading "!" names the binary itself
+func isOwnHelper(value string) bool {
+	command := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "!"))
+	var name string
+	if strings.HasPrefix(command, `"`) || strings.HasPrefix(command, "'") {
+		// a quoted path may contain spaces
+		name, _, _ = strings.Cut(command[1:], command[:1])
+	} else if fields := strings.Fields(command); len(fields) > 0 {
+		name = fields[0]
+	}
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.TrimSuffix(name, ".exe")
+	return name == "1password" || name == appName
+}
+
+// activeHelpers returns the credential helpers git runs in their order, an
+// empty entry resets the list like it does in git
+func activeHelpers() ([]configuredHelper, error) {
+	output, err := exec.Command("git", "config", "--show-origin", "--get-all", "credential.helper").Output()
+	if err != nil {
+		// git exits with 1 if the key is not set
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("git config failed with %s", err)
+	}
+	var helpers []configuredHelper
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		origin, value, _ := strings.Cut(line, "\t")
+		if value == "" {
+			helpers = nil
+			continue
+		}
+		helpers = append(helpers, configuredHelper{Origin: origin, Value: value})
+	}
+	return helpers, nil
+}
+
+// competingHelpers explains how the other active helpers interfere with
+// this one, helpers asked before it shadow the credentials in 1Password and
+// all of them store every credential git approves a second time
+func competingHelpers() []string {
+	helpers, err := activeHelpers()
+	if err != nil {
+		return nil
+	}
+	own := -1
+	for i, h := range helpers {
+		if isOwnHelper(h.Value) {
+			own = i
+			break
+		}
+	}
+	if own < 0 {
+		return nil
+	}
+	var notes []string
+	for i, h := range helpers {
+		switch {
+		case isOwnHelper(h.Value):
+			continue
+		case i < own:
+			notes = append(notes, fmt.Sprintf("credential helper %q (%s) is asked first, credentials it knows are never read from 1Password", h.Value, strings.TrimPrefix(h.Origin, "file:")))
+		default:
+			notes = append(notes, fmt.Sprintf("credential helper %q (%s) stores every credential a second time", h.Value, strings.TrimPrefix(h.Origin, "file:")))
+		}
+	}
+	if len(notes) > 0 {
+		notes = append(notes, `use 1Password only with: git config --global --replace-all credential.helper "" && git config --global --add credential.helper 1password`)
+	}
+	return notes
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// chooseOne lets the user pick one of the options by number, an empty answer
+// picks none
+func chooseOne(title string, options []string) int {
+	fmt.Println(title)
+	for i, option := range options {
+		fmt.Printf("  %d) %s\n", i+1, option)
+	}
+	for {
+		answer := ask("Number (empty for the default):", "")
+		if answer == "" {
+			return -1
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return n - 1
+		}
+	}
+}
+
+// writeConfigKeys merges the keys into the config file, keeping all other
+// settings of an existing file
+func writeConfigKeys(p string, keys map[string]any) error {
+	settings := make(map[string]any)
+	raw, err := os.ReadFile(p)
+	if err == nil {
+		if err = json.Unmarshal(raw, &settings); err != nil {
+			return fmt.Errorf("parsing config %s failed with %s", p, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for k, v := range keys {
+		if v == nil {
+			delete(settings, k)
+		} else {
+			settings[k] = v
+		}
+	}
+	if raw, err = json.MarshalIndent(settings, "", "  "); err != nil {
+		return err
+	}
+	if err = ensurePrivateDir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	return os.WriteFile(p, append(raw, '\n'), 0o600)
+}
+
+// shellQuote quotes a value for the helper command line in the git config,
+// which git runs through the shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Init walks a new user through the setup: it checks op, lets the user choose
+// account, vault, prefix and optional features, writes the config file and
+// the git config and finishes with a live lookup
+func Init() error {
+	fmt.Println("Setting up git-credential-1password")
+	if isRemoteConfig(configPath) {
+		return fmt.Errorf("the config is read from %s, change it in 1Password", configPath)
+	}
+
+	// verify op access first, nothing else works without it
+	v, err := opVersion()
+	if err != nil {
+		return fmt.Errorf("the 1Password CLI (op) was not found, install it from https://developer.1password.com/docs/cli/get-started/")
+	}
+	fmt.Printf("Found op %s\n", v)
+	if !opUnlocked() {
+		fmt.Println("op is not signed in, run `op signin` or enable CLI integration in the 1Password app (Settings > Developer)")
+		if !confirm("Continue anyway?") {
+			return errors.New("setup aborted")
+		}
+	}
+
+	helper := []string{"1password"}
+	if accounts, err := discoverAccounts(); err == nil && len(accounts) > 1 {
+		var options []string
+		for _, a := range accounts {
+			options = append(options, a.Email+" ("+a.URL+")")
+		}
+		if i := chooseOne("Which account should be used?", options); i >= 0 {
+			account = accounts[i].AccountUUID
+			opFlags = append(opFlags, "--account", account)
+			helper = append(helper, "--account="+account)
+		}
+	}
+
+	if vaults, err := discoverVaults(); err == nil && len(vaults) > 0 {
+		var options []string
+		for _, v := range vaults {
+			options = append(options, v.Name)
+		}
+		if i := chooseOne("Which vault should store the credentials?", options); i >= 0 {
+			vault = vaults[i].ID
+			helper = append(helper, "--vault="+shellQuote(vaults[i].Name))
+		}
+	}
+
+	prefix = ask("Prefix for item names (e.g. \"Git: \"), empty for none:", "")
+	if prefix != "" {
+		helper = append(helper, "--prefix="+shellQuote(prefix))
+	}
+
+	keys := map[string]any{"daemon": nil, "tag": nil}
+	if confirm("Cache results in a background daemon for faster git operations?") {
+		keys["daemon"] = true
+	}
+	if tag := ask("Tag for items created by the helper, empty for none:", ""); tag != "" {
+		keys["tag"] = tag
+	}
+	if err = writeConfigKeys(configPath, keys); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", configPath)
+
+	// configure git, only replacing existing entries of this helper
+	scope := "credential.helper"
+	if host := ask("Use the helper for a single host only (e.g. https://gitlab.example.net), empty for all:", ""); host != "" {
+		scope = "credential." + host + ".helper"
+	}
+	value := strings.Join(helper, " ")
+	if output, err := exec.Command("git", "config", "--global", "--replace-all", scope, value, "1password").CombinedOutput(); err != nil {
+		return fmt.Errorf("git config failed with %s %s", err, output)
+	}
+	fmt.Printf("Set git config --global %s %q\n", scope, value)
+	for _, note := range competingHelpers() {
+		fmt.Printf("Warning: %s\n", note)
+	}
+
+	// live round trip, without revealing the secret
+	if host := ask("Host to test the lookup with (empty to skip):", ""); host != "" {
+		_, item, err := lookupRecentItem(vault, resolveHost(host), host, hostConfig(host).Username)
+		if err != nil {
+			fmt.Printf("No credential found for %s yet, it is stored after the next successful login\n", host)
+		} else {
+			fmt.Printf("Found %q with username %q\n", item.Title, item.username())
+		}
+	}
+	fmt.Println("Done")
+	return nil
+}
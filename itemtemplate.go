@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// itemTemplate returns the template of new items for the host as raw json
+// object, the template of the host wins over the global one and a string is
+// read as path of a json file
+func itemTemplate(hc HostConfig) (map[string]json.RawMessage, error) {
+	raw := config.ItemTemplate
+	if len(hc.ItemTemplate) > 0 {
+		raw = hc.ItemTemplate
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var p string
+	if json.Unmarshal(raw, &p) == nil {
+		// relative paths are relative to the config file
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(configPath), p)
+		}
+		var err error
+		if raw, err = os.ReadFile(p); err != nil {
+			return nil, fmt.Errorf("reading the item template failed with %s", err)
+		}
+	}
+	var template map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &template); err != nil {
+		return nil, fmt.Errorf("invalid item template: %s", err)
+	}
+	return template, nil
+}
+
+// checkItemTemplate validates an itemTemplate setting, it must be a json
+// object or the path of a file
+func checkItemTemplate(raw json.RawMessage) error {
+	if len(raw) == 0 {
+		return nil
+	}
+	var p string
+	var template map[string]json.RawMessage
+	if json.Unmarshal(raw, &p) != nil && json.Unmarshal(raw, &template) != nil {
+		return fmt.Errorf("invalid itemTemplate, expected an object or the path of a json file")
+	}
+	return nil
+}
+
+// templateTags returns the tags of the template
+func templateTags(template map[string]json.RawMessage) []string {
+	var tags []string
+	json.Unmarshal(template["tags"], &tags)
+	return tags
+}
+
+// mergeCredential returns the item template with the username and password
+// of a login, fields of the template with their purpose or id are replaced,
+// all other fields and sections are kept, op reads the template from stdin
+// so values are kept verbatim while assignment statements on the command
+// line would not survive newlines
+func mergeCredential(template map[string]json.RawMessage, username string, password string) ([]byte, error) {
+	item := make(map[string]json.RawMessage, len(template)+1)
+	for k, v := range template {
+		item[k] = v
+	}
+	var fields []map[string]any
+	if raw, ok := item["fields"]; ok {
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, fmt.Errorf("invalid fields in the item template: %s", err)
+		}
+	}
+	kept := fields[:0]
+	for _, field := range fields {
+		purpose, _ := field["purpose"].(string)
+		id, _ := field["id"].(string)
+		if !strings.EqualFold(purpose, "USERNAME") && !strings.EqualFold(purpose, "PASSWORD") &&
+			id != "username" && id != "password" {
+			kept = append(kept, field)
+		}
+	}
+	kept = append(kept,
+		map[string]any{"id": "username", "label": "username", "type": "STRING", "purpose": "USERNAME", "value": username},
+		map[string]any{"id": "password", "label": "password", "type": "CONCEALED", "purpose": "PASSWORD", "value": password},
+	)
+	var err error
+	if item["fields"], err = json.Marshal(kept); err != nil {
+		return nil, err
+	}
+	return json.Marshal(item)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// keyringService is the service name of all keyring entries of the helper
+const keyringService = "git-credential-1password"
+
+var errKeyringUnsupported = errors.New("no supported OS keyring found")
+
+// dpapiPath returns the file of a secret encrypted with DPAPI, Windows has
+// no keyring for programs to use, so the encrypted secrets are kept in the
+// state directory
+func dpapiPath(key string) string {
+	return appFile(stateDir, filepath.Join("keyring", hex.EncodeToString([]byte(key))))
+}
+
+// keyringGet reads a secret from the OS keyring (macOS Keychain, the Secret
+// Service on Linux via secret-tool or a file encrypted with DPAPI on
+// Windows)
+func keyringGet(key string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		encrypted, err := os.ReadFile(dpapiPath(key))
+		if err != nil {
+			return "", fmt.Errorf("keyring lookup failed with %s", err)
+		}
+		secret, err := dpapiUnprotect(encrypted)
+		if err != nil {
+			return "", fmt.Errorf("keyring lookup failed with %s", err)
+		}
+		return string(secret), nil
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", key, "-w")
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", key)
+	default:
+		return "", errKeyringUnsupported
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("keyring lookup failed with %s", err)
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// keyringSet writes a secret to the OS keyring, replacing an existing entry
+func keyringSet(key string, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		if noDisk {
+			return fmt.Errorf("keyring store failed, the keyring is a file on Windows, refused by --no-disk")
+		}
+		encrypted, err := dpapiProtect([]byte(secret))
+		if err != nil {
+			return fmt.Errorf("keyring store failed with %s", err)
+		}
+		p := dpapiPath(key)
+		if err = ensurePrivateDir(filepath.Dir(p)); err != nil {
+			return fmt.Errorf("keyring store failed with %s", err)
+		}
+		if err = os.WriteFile(p, encrypted, 0o600); err != nil {
+			return fmt.Errorf("keyring store failed with %s", err)
+		}
+		return nil
+	case "darwin":
+		// with -w as the last argument security asks for the secret twice
+		// and reads it from stdin, so it never shows up in the process list
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keyringService, "-a", key, "-w")
+		cmd.Stdin = strings.NewReader(secret + "\n" + secret + "\n")
+	case "linux", "freebsd", "openbsd", "netbsd":
+		// secret-tool reads the secret from stdin, so it never shows up in
+		// the process list
+		cmd = exec.Command("secret-tool", "store", "--label", keyringService+" "+key, "service", keyringService, "account", key)
+		cmd.Stdin = strings.NewReader(secret)
+	default:
+		return errKeyringUnsupported
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keyring store failed with %s %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// keyringDelete removes a secret from the OS keyring
+func keyringDelete(key string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		if err := os.Remove(dpapiPath(key)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("keyring delete failed with %s", err)
+		}
+		return nil
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", key)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("secret-tool", "clear", "service", keyringService, "account", key)
+	default:
+		return errKeyringUnsupported
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keyring delete failed with %s %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+// dpapiProtect is only available on Windows
+func dpapiProtect(data []byte) ([]byte, error) {
+	return nil, errKeyringUnsupported
+}
+
+// dpapiUnprotect is only available on Windows
+func dpapiUnprotect(data []byte) ([]byte, error) {
+	return nil, errKeyringUnsupported
+}
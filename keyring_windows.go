@@ -0,0 +1,61 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	crypt32                = syscall.NewLazyDLL("crypt32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = syscall.NewLazyDLL("kernel32.dll").NewProc("LocalFree")
+)
+
+// cryptProtectUIForbidden makes DPAPI fail instead of showing a prompt
+const cryptProtectUIForbidden = 0x1
+
+// dataBlob is the DATA_BLOB of the Windows API
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+// newDataBlob returns a blob pointing to b
+func newDataBlob(b []byte) *dataBlob {
+	if len(b) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{size: uint32(len(b)), data: &b[0]}
+}
+
+// take copies the data of a blob allocated by Windows and frees it
+func (b *dataBlob) take() []byte {
+	out := make([]byte, b.size)
+	copy(out, unsafe.Slice(b.data, b.size))
+	procLocalFree.Call(uintptr(unsafe.Pointer(b.data)))
+	return out
+}
+
+// dpapiProtect encrypts data with the key of the Windows user
+func dpapiProtect(data []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := procCryptProtectData.Call(uintptr(unsafe.Pointer(newDataBlob(data))), 0, 0, 0, 0, cryptProtectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, fmt.Errorf("CryptProtectData failed with %s", err)
+	}
+	return out.take(), nil
+}
+
+// dpapiUnprotect decrypts data encrypted by dpapiProtect for the same user
+func dpapiUnprotect(data []byte) ([]byte, error) {
+	var out dataBlob
+	r, _, err := procCryptUnprotectData.Call(uintptr(unsafe.Pointer(newDataBlob(data))), 0, 0, 0, 0, cryptProtectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, fmt.Errorf("CryptUnprotectData failed with %s", err)
+	}
+	return out.take(), nil
+}
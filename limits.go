@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	// maxOpOutput limits the output of op that is read, unusually big
+	// items or lists must not balloon the memory of the helper. The output
+	// is buffered completely before it is decoded, the limit is what bounds
+	// the memory
+	maxOpOutput = 32 << 20
+	// maxOpStderr limits the error output of op that is kept
+	maxOpStderr = 1 << 20
+	// maxCachedResult is the largest result the daemon keeps in its cache
+	maxCachedResult = 1 << 20
+)
+
+// errOutputTooLarge is returned when op writes more than maxOpOutput
+var errOutputTooLarge = fmt.Errorf("the output of op exceeds %d MiB", maxOpOutput>>20)
+
+// limitedBuffer is a buffer that fails writes beyond its limit, which stops
+// reading the output of the command
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	err      error
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		b.exceeded = true
+		return 0, b.err
+	}
+	return b.Buffer.Write(p)
+}
+
+// opOutputBuffers returns the buffers for the standard and error output of
+// op, the error output is truncated silently
+func opOutputBuffers() (*limitedBuffer, *truncatingBuffer) {
+	return &limitedBuffer{limit: maxOpOutput, err: errOutputTooLarge}, &truncatingBuffer{limit: maxOpStderr}
+}
+
+// truncatingBuffer keeps the first limit bytes and discards the rest
+type truncatingBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *truncatingBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(p) {
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// flushLocalData stops the daemon and removes the item references of the
+// state, the caches and keyring entries of the helper, every removed entry
+// is printed
+func flushLocalData() {
+	if stopDaemon() {
+		fmt.Println("Stopped the daemon")
+	}
+
+	state := loadState()
+	keys := []string{sessionKey(), "session:", offlineCacheKey}
+	for _, a := range state.Accounts.Accounts {
+		for _, id := range []string{a.URL, a.Email, a.AccountUUID, a.UserUUID} {
+			if id != "" {
+				keys = append(keys, "session:"+id)
+			}
+		}
+	}
+	for key := range state.Items {
+		keys = append(keys, "credential:"+key)
+	}
+	for host := range state.Vaults {
+		keys = append(keys, "credential:"+stateKey(host, ""))
+	}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if keyringDelete(key) == nil {
+			fmt.Printf("Removed keyring entry %s\n", key)
+		}
+	}
+
+	// only the references to items, vaults and accounts are dropped from
+	// the state, the rate limit has to survive a lock
+	if len(state.Items) > 0 || len(state.Vaults) > 0 || len(state.Discovery) > 0 || len(state.Accounts.Accounts) > 0 || len(state.DatabaseURLs) > 0 {
+		err := updateState(func(state *State) {
+			state.Items = nil
+			state.Vaults = nil
+			state.Discovery = nil
+			state.Accounts = AccountDiscovery{}
+			state.DatabaseURLs = nil
+		})
+		if err == nil {
+			fmt.Printf("Removed the items, vaults and accounts from %s\n", statePath())
+		}
+	}
+	paths := append([]string{offlineCachePath(), daemonSocketPath()}, remoteConfigCaches()...)
+	for _, p := range paths {
+		if p != "" && os.Remove(p) == nil {
+			fmt.Printf("Removed %s\n", p)
+		}
+	}
+}
+
+// Lock flushes every cache and stored session, e.g. before handing over the
+// machine or when a compromise is suspected
+func Lock(args []string) error {
+	flags := flag.NewFlagSet("lock", flag.ExitOnError)
+	signout := flags.Bool("signout", false, "Also sign out of op")
+	flags.Parse(args)
+
+	flushLocalData()
+	if *signout {
+		// run op directly, the daemon was stopped above
+		cmd := exec.Command("op", append([]string{"signout"}, opFlags...)...)
+		cmd.Env = opEnv()
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("op signout failed with %s\n%s", err, output)
+		}
+		fmt.Println("Signed out of op")
+	}
+	return nil
+}
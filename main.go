@@ -1,15 +1,18 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // OpItem is the struct for the output of "op item get --format json" command
@@ -17,24 +20,83 @@ import (
 // to get the username and password, nothing else
 // Reference: https://support.1password.com/command-line-reference/#item-get
 type OpItem struct {
+	ID    string `json:"id,omitempty"`
 	Label string `json:"label,omitempty"`
 	Value string `json:"value,omitempty"`
+	// Type is e.g. STRING or CONCEALED
+	Type string `json:"type,omitempty"`
+	// Purpose marks the username and password fields of Login items
+	// independent of their label, e.g. USERNAME or PASSWORD
+	Purpose string `json:"purpose,omitempty"`
+	// Reference is the secret reference of the field
+	Reference string `json:"reference,omitempty"`
 }
 
 type OpItemList []OpItem
 
+// OpVault is the vault reference of an item
+type OpVault struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// OpFullItem is the output of "op item get --format json" without limiting
+// the fields, besides the fields it carries the id and vault of the item
+type OpFullItem struct {
+	ID    string  `json:"id"`
+	Title string  `json:"title"`
+	Vault OpVault `json:"vault"`
+	// Category is e.g. LOGIN or DATABASE
+	Category string     `json:"category,omitempty"`
+	URLs     []OpURL    `json:"urls,omitempty"`
+	Fields   OpItemList `json:"fields,omitempty"`
+}
+
 // versioning is not yet implemented
 var (
-	prefix      string
-	opItemFlags []string
-	version     = "main"
+	prefix  string
+	account string
+	vault   string
+	opFlags []string
+	opArgs  []string
+	verbose bool
+	quiet   bool
+	force   bool
+	version = "main"
 )
 
-// GetField returns the value of the field with the given label
-func (i OpItemList) GetField(label string) string {
-	for _, field := range i {
-		if field.Label == label {
-			return field.Value
+// username returns the username of the item, nil items have none
+func (i *OpFullItem) username() string {
+	if i == nil {
+		return ""
+	}
+	return i.Fields.GetField("username")
+}
+
+// GetField returns the value of the field with the given name: the field
+// with the matching purpose ("username" and "password") wins, so items with
+// translated or renamed labels resolve, then the field id, the label and
+// finally the translated labels of fieldLabels
+func (i OpItemList) GetField(name string) string {
+	purpose := strings.ToUpper(name)
+	aliases := fieldLabels(name)
+	for _, match := range []func(OpItem) bool{
+		func(f OpItem) bool { return f.Purpose != "" && f.Purpose == purpose },
+		func(f OpItem) bool { return f.ID == name },
+		func(f OpItem) bool { return f.Label == name },
+		func(f OpItem) bool {
+			for _, alias := range aliases {
+				if strings.EqualFold(f.Label, alias) {
+					return true
+				}
+			}
+			return false
+		},
+	} {
+		for _, field := range i {
+			if match(field) {
+				return field.Value
+			}
 		}
 	}
 	return ""
@@ -49,74 +111,303 @@ func getVersion() string {
 	return version
 }
 
+// actions lists the actions shown in the usage and offered by completion,
+// hosts marks the actions whose argument is completed with known hosts
+var actions = []struct {
+	name  string
+	usage string
+	hosts bool
+}{
+	{"get", "Generate credential [called by Git]", false},
+	{"store", "Store credential [called by Git]", false},
+	{"erase", "Erase credential [called by Git], or of the given host", true},
+	{"conflicts", "List hosts matched by more than one item", false},
+	{"report", "Cross-check the items by title, tag and website", false},
+	{"stale", "List credentials not used for a long time [--older-than 90d] [--archive]", false},
+	{"signin", "Sign in and store the session in the OS keyring", false},
+	{"daemon", "Run the cache daemon [started automatically]", false},
+	{"init", "Set up the helper interactively", false},
+	{"add-host", "Create the item of a host and print its git config <url>", false},
+	{"serve", "Answer JSON-RPC requests of editors and GUI clients --stdio", false},
+	{"explain", "Show how a request resolves to an item [--fields] <host>", true},
+	{"env", "Print secrets as environment variables <host|op-ref>[:NAME]...", true},
+	{"import", "Store the token of gh, glab or tea in 1Password <source> [<host>]", false},
+	{"header", "Print the custom header of a host for http.extraHeader <host>", true},
+	{"ssh-key", "Add the SSH key of a host to the ssh-agent [--file] [--lifetime <duration>] <host>", true},
+	{"reveal", "Print or copy the stored credential of a host [--copy] <host>", true},
+	{"verify-protocol", "Check the helper against the installed git with crafted credentials", false},
+	{"purge-sandbox", "Delete the items created in the vault of --sandbox-vault", false},
+	{"bench", "Measure the latency of get for a host [-n <iterations>] <host>", true},
+	{"lock", "Flush all caches and stored sessions [--signout]", false},
+	{"uninstall", "Remove git config, daemon and local data [--archive-items] [--yes]", false},
+	{"vaults", "List the vaults of the account", false},
+	{"accounts", "List the accounts configured in op", false},
+	{"doctor", "Check the setup and show the state of the helper", false},
+	{"config", "Check the config file for unknown keys and invalid rules: validate", false},
+	{"prefetch", "Prime the cache daemon for all remotes and submodules [<dir>]", false},
+	{"completion", "Print shell completion script [bash|zsh|fish]", false},
+}
+
+// warnf prints a warning to stderr unless --quiet is given
+func warnf(format string, args ...any) {
+	if !quiet {
+		log.Printf(style(stderrColor(), "33", "warning:")+" "+format, args...)
+	}
+}
+
 // PrintVersion prints the version of the binary
 func PrintVersion() {
 	fmt.Fprintf(os.Stderr, "git-credential-1password %s\n", getVersion())
 }
 
-// get 1password item name
-func itemName(host string) string {
+// titleSeparator separates host and username in item titles
+const titleSeparator = " – "
+
+// get 1password item name, the username is only part of the name if enabled
+// in the config
+func itemName(host string, username string) string {
+	if config.UsernameInTitle && username != "" {
+		return fmt.Sprintf("%s%s%s%s", prefix, host, titleSeparator, username)
+	}
 	return fmt.Sprintf("%s%s", prefix, host)
 }
 
-// build a exec.Cmd for "op item" sub command including additional flags
-func buildOpItemCommand(subcommand string, args ...string) *exec.Cmd {
-	cmdArgs := []string{"item", subcommand}
-	cmdArgs = append(cmdArgs, opItemFlags...)
+// ItemRef identifies a 1Password item by its title or id and the vault it
+// lives in, an empty vault means the default vault of the account
+type ItemRef struct {
+	Vault string `json:"vault,omitempty"`
+	Item  string `json:"item"`
+}
+
+// parseItemRef parses either a plain item title or a secret reference in the
+// form "op://<vault>/<item>[/...]"
+func parseItemRef(s string) ItemRef {
+	if rest, ok := strings.CutPrefix(s, "op://"); ok {
+		parts := strings.Split(rest, "/")
+		if len(parts) >= 2 {
+			return ItemRef{Vault: parts[0], Item: parts[1]}
+		}
+	}
+	return ItemRef{Item: s}
+}
+
+// get the 1password item for a host, an item pinned in the git config of
+// the repository and matching rules from the config file take precedence
+// over the prefixed host name, which is looked up in the given vault
+func itemRef(vault string, host string, username string) ItemRef {
+	if pinnedItem != "" {
+		return pinnedItemRef()
+	}
+	ref, ok := matchRule(host)
+	if group, isGroup := config.Groups[host]; !ok && isGroup && group.Item != "" {
+		ref, ok = parseItemRef(group.Item), true
+	}
+	if !ok {
+		ref = ItemRef{Item: itemName(host, username)}
+	}
+	if ref.Vault == "" {
+		ref.Vault = vault
+	}
+	return ref
+}
+
+// build a exec.Cmd for an op command and sub command including additional
+// flags
+func buildOpCommand(command string, subcommand string, args ...string) *exec.Cmd {
+	cmdArgs := []string{command, subcommand}
+	cmdArgs = append(cmdArgs, opFlags...)
+	cmdArgs = append(cmdArgs, opArgs...)
 	cmdArgs = append(cmdArgs, args...)
 	return exec.Command("op", cmdArgs...)
 }
 
-// opGetItem runs "op item get --format json" command with the given name
-func opGetItem(n string) (OpItemList, error) {
-	// --fields username,password limits the output to only username and password
-	opItemGet := buildOpItemCommand("get", "--format", "json", "--fields", "username,password", n)
-	opItemRaw, err := opItemGet.CombinedOutput()
+// build a exec.Cmd for "op item" sub command including additional flags
+func buildOpItemCommand(subcommand string, vault string, args ...string) *exec.Cmd {
+	if vault != "" {
+		args = append([]string{"--vault=" + vault}, args...)
+	}
+	return buildOpCommand("item", subcommand, args...)
+}
+
+// runOp runs an op command and returns its standard output, the error
+// includes the standard error output of op. If the session stored in the
+// keyring expired, a new one is requested and the command is retried once.
+// Rate limited commands are paced and retried
+func runOp(cmd *exec.Cmd) ([]byte, error) {
+	var stdin []byte
+	if cmd.Stdin != nil {
+		var err error
+		if stdin, err = io.ReadAll(cmd.Stdin); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	defer func() { opElapsed += time.Since(start) }()
+	done := traceOp(cmd.Args[1:])
+
+	waitRateLimit()
+	output, err := execOp(cmd.Args[1:], stdin)
+	for retry := 0; err != nil && retry < rateLimitRetries && isRateLimited(err); retry++ {
+		time.Sleep(recordRateLimit())
+		output, err = execOp(cmd.Args[1:], stdin)
+	}
+	if err != nil && config.SessionKeyring && !ciMode && isSessionError(err) {
+		if _, signinErr := refreshSession(); signinErr == nil {
+			output, err = execOp(cmd.Args[1:], stdin)
+		}
+	}
+	done(err)
+	return output, err
+}
+
+// execOp runs op with the given arguments, if enabled the command is served
+// by the daemon
+func execOp(args []string, stdin []byte) ([]byte, error) {
+	if (config.Daemon && !ciMode) || cacheOnly {
+		if result, ok := daemonRunOp(args, stdin, cacheOnly); ok {
+			return result.output()
+		}
+	}
+	if cacheOnly {
+		return nil, errNotCached
+	}
+
+	var stdout *limitedBuffer
+	var stderr *truncatingBuffer
+	err := runBackends(func(env []string) error {
+		stdout, stderr = opOutputBuffers()
+		cmd := exec.Command("op", args...)
+		cmd.Env = env
+		if stdin != nil {
+			cmd.Stdin = bytes.NewReader(stdin)
+		}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s\n%s", err, bytes.TrimSpace(stderr.Bytes()))
+		}
+		return nil
+	})
+	if stdout.exceeded {
+		return nil, errOutputTooLarge
+	}
+	if err != nil {
+		return stdout.Bytes(), err
+	}
+	// op prints update notices and warnings on stderr, these would end up
+	// in the output of git, so only show them on request
+	if verbose {
+		os.Stderr.Write(stderr.Bytes())
+	}
+	return stdout.Bytes(), nil
+}
+
+// opGetItem runs "op item get --format json" command with the given item
+func opGetItem(ref ItemRef) (*OpFullItem, error) {
+	if err := checkItemArg(ref.Item); err != nil {
+		return nil, err
+	}
+	opItemGet := buildOpItemCommand("get", ref.Vault, "--format", "json", ref.Item)
+	opItemRaw, err := runOp(opItemGet)
 	if err != nil {
-		return nil, fmt.Errorf("opItemGet failed with %s\n%+s", err, opItemRaw)
+		return nil, fmt.Errorf("opItemGet failed with %s", err)
 	}
 
-	// marhsal the raw output to OpItem struct
-	var opItem OpItemList
+	// marhsal the raw output to OpFullItem struct
+	var opItem *OpFullItem
 	if err = json.Unmarshal(opItemRaw, &opItem); err != nil {
 		return nil, fmt.Errorf("json.Unmarshal() failed with %s", err)
 	}
 	return opItem, nil
 }
 
-// ReadLines reads the input from stdin and returns a map of key value pairs
-func ReadLines() (inputs map[string]string) {
-	inputs = make(map[string]string)
-	// create stdin reader
-	reader := bufio.NewReader(os.Stdin)
-
-	for {
-		// line by line read from stdin
-		line, _ := reader.ReadString('\n')
+// lookupItem gets the item for a host in the vault, unless a rule names
+// another one. If the expected title is ambiguous or no such item exists,
+// items with a website entry for the host are considered as well and the best
+// ranked candidate is used. If a username is given, items with that username
+// are preferred
+func lookupItem(vault string, host string, requestHost string, username string) (ItemRef, *OpFullItem, error) {
+	ref := itemRef(vault, host, username)
+	item, err := opGetItem(ref)
+	// the pinned item is used as it is, other items are never considered
+	if pinnedItem != "" {
+		return ref, item, err
+	}
+	if err == nil && usableItem(item, requestHost, username) {
+		return ref, item, nil
+	}
+	fallback := item
 
-		// if the line is empty, break the loop
-		if line == "" || line == "\n" {
-			break
+	vaults := []string{ref.Vault}
+	// optionally search the remaining vaults of the account
+	if config.SearchVaults {
+		vaults = append(vaults, searchVaults(ref.Vault)...)
+	}
+	for _, v := range vaults {
+		for _, candidate := range findItems(itemRef(vault, host, "").Item, []string{requestHost, host}, v) {
+			item, candidateErr := opGetItem(candidate)
+			if candidateErr != nil {
+				continue
+			}
+			if usableItem(item, requestHost, username) {
+				return candidate, item, nil
+			}
 		}
+	}
 
-		// create a slice of strings by splitting the line
-		parts := strings.SplitN(line, "=", 2)
+	if fallback != nil {
+		return ref, fallback, nil
+	}
+	return ref, nil, err
+}
 
-		// see if this can a key value pair
-		if len(parts) == 2 {
-			inputs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-		} else {
-			log.Fatalf("Invalid input: %s", line)
-		}
+// usableItem reports whether the item serves the request: it must have the
+// username if one is given and, with skipPasskeyItems, a password
+func usableItem(item *OpFullItem, requestHost string, username string) bool {
+	if username != "" && item.Fields.GetField("username") != username {
+		return false
+	}
+	return !config.SkipPasskeyItems || !item.passkeyOnly(hostConfig(requestHost))
+}
+
+// gitBool parses a boolean attribute like git does
+func gitBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// ReadLines reads the attributes of the request from stdin
+func ReadLines() Attributes {
+	inputs, err := parseAttributes(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
 	}
 	return inputs
 }
 
 func main() {
+	defer recoverPanic()
+
 	accountFlag := flag.String("account", "", "1Password account")
 	vaultFlag := flag.String("vault", "", "1Password vault")
 	prefixFlag := flag.String("prefix", "", "1Password item name prefix")
+	configFlag := flag.String("config", "", "Path to config file (default "+defaultConfigPath()+")")
 	versionFlag := flag.Bool("version", false, "Print version")
+	flag.BoolVar(&verbose, "verbose", false, "Show the output of op on stderr")
+	flag.BoolVar(&quiet, "quiet", false, "Do not print warnings")
+	flag.BoolVar(&force, "force", false, "Overwrite credentials of a different username without asking and create items beyond maxCreatesPerHour")
+	flag.BoolVar(&ciMode, "ci", false, "Mask secrets in CI logs and never prompt")
+	flag.BoolVar(&noDisk, "no-disk", false, "Refuse features that write secrets to files on disk")
+	flag.StringVar(&sandboxVault, "sandbox-vault", "", "Confine the helper to this vault with default settings, for automated tests")
+	portableFlag := flag.Bool("portable", false, "Keep config, state and cache next to the binary")
+	flag.Func("op-arg", "Additional argument for every op invocation (repeatable)", func(arg string) error {
+		opArgs = append(opArgs, arg)
+		return nil
+	})
 
 	flag.Usage = func() {
 		PrintVersion()
@@ -126,9 +417,9 @@ func main() {
 		flag.PrintDefaults()
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "Actions:")
-		fmt.Fprintln(os.Stderr, "  get            Generate credential [called by Git]")
-		fmt.Fprintln(os.Stderr, "  store          Store credential [called by Git]")
-		fmt.Fprintln(os.Stderr, "  erase          Erase credential [called by Git]")
+		for _, action := range actions {
+			fmt.Fprintf(os.Stderr, "  %-14s %s\n", action.name, action.usage)
+		}
 		fmt.Fprintln(os.Stderr, "")
 		fmt.Fprintln(os.Stderr, "See also https://github.com/ethrgeist/git-credential-1password")
 	}
@@ -136,22 +427,118 @@ func main() {
 
 	if *versionFlag {
 		PrintVersion()
+		PrintAdvisories()
 		os.Exit(0)
 	}
 
 	args := flag.Args()
-	if len(args) != 1 {
+	if len(args) == 0 {
 		flag.Usage()
 		os.Exit(2)
 	}
 
+	// emergency bypass, takes the helper out of the loop without editing
+	// the git config
+	if disabled, _ := strconv.ParseBool(os.Getenv("GIT_CREDENTIAL_1PASSWORD_DISABLE")); disabled {
+		switch args[0] {
+		case "get", "store", "erase":
+			io.Copy(io.Discard, os.Stdin)
+			return
+		}
+	}
+
+	if err := setupPortable(*portableFlag); err != nil {
+		log.Fatal(err)
+	}
+
+	// settings passed with "git -c credential.1password.<name>=..." win
+	// over the options of the helper for this git command
+	for name, value := range gitConfigOverrides() {
+		flag.Set(name, value)
+	}
+
+	// the account is needed to read a config from 1Password
+	account = *accountFlag
+	if account != "" {
+		opFlags = append(opFlags, "--account", account)
+	}
+
+	// load the optional config file
+	configPath = *configFlag
+	if configPath == "" {
+		configPath = defaultConfigPath()
+	}
+	// config validate reports the issues of a broken config itself
+	if args[0] != "config" {
+		if err := loadConfig(configPath, *configFlag != ""); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// the offline cache is a file on disk, even if encrypted
+	if noDisk {
+		config.OfflineCache = false
+	}
+
+	// optionally trace the action, the long-lived daemon is not traced
+	switch args[0] {
+	case "daemon", "__complete", "__clear-clipboard", "__remove-secret-file":
+	default:
+		startTrace(args[0])
+		defer finishTrace("")
+	}
+
 	// set global variables based on flags
 	prefix = *prefixFlag
-	if *accountFlag != "" {
-		opItemFlags = append(opItemFlags, "--account", *accountFlag)
+	opArgs = append(config.OpArgs, opArgs...)
+	if config.SessionKeyring {
+		loadSession()
+	}
+	vault = *vaultFlag
+	if sandboxVault != "" {
+		if err := enterSandbox(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// tell new users once what the helper does to their vaults
+	switch args[0] {
+	case "get", "store", "erase":
+		maybeShowFirstRun()
 	}
-	if *vaultFlag != "" {
-		opItemFlags = append(opItemFlags, "--vault", *vaultFlag)
+
+	// a repository can be pinned to an item with credential.1password.item
+	switch args[0] {
+	case "get", "store", "erase":
+		pinnedItem = gitConfigGet(gitConfigSection + "item")
+	}
+
+	// background callers like IDE auto-fetch must never trigger a prompt,
+	// if 1Password is locked, only cached results are used
+	switch args[0] {
+	case "get", "store", "erase":
+		if !ciMode && isBackground() && !opUnlocked() {
+			cacheOnly = true
+		}
+	}
+
+	// validate vaults up front, instead of failing with a generic error of
+	// op deep inside a git push
+	switch args[0] {
+	case "get", "store", "erase", "conflicts", "report", "stale", "prefetch", "bench", "reveal", "import", "env", "serve", "header", "purge-sandbox", "verify-protocol", "add-host", "ssh-key":
+		if cacheOnly {
+			break
+		}
+		var err error
+		if vault != "" {
+			if vault, err = validateVault(vault); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if config.WritableVault != "" {
+			if config.WritableVault, err = validateVault(config.WritableVault); err != nil {
+				log.Fatal(err)
+			}
+		}
 	}
 
 	// git provides argument via stdin
@@ -160,50 +547,147 @@ func main() {
 	case "get":
 		// git sends the input to stdin
 		gitInputs := ReadLines()
-
-		// check if the host field is present in the input
-		if _, ok := gitInputs["host"]; !ok {
-			log.Fatalf("host is missing in the input")
-		}
-
-		// run "op item get --format json" command with the host value
-		// this can only get, no other operations are allowed
-		opItem, err := opGetItem(itemName(gitInputs["host"]))
+		credential, err := getCredentialAfterUnlock(gitInputs)
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		// feed the username and password to git
-		username := opItem.GetField("username")
-		password := opItem.GetField("password")
-		if username == "" || password == "" {
-			log.Fatalf("username or password is empty, is the item named correctly?")
+		if credential == nil {
+			return
+		}
+		attrs := append(credential.attributes(), unknownAttributes(gitInputs)...)
+		if err := newResponseWriter(os.Stdout).WriteAttributes(attrs); err != nil {
+			log.Fatal(err)
 		}
-		fmt.Printf("username=%s\n", username)
-		fmt.Printf("password=%s\n", password)
 	case "store":
 		gitInputs := ReadLines()
-
-		item, _ := opGetItem(itemName(gitInputs["host"]))
-		if item == nil {
-			// run "op create item" command with the host value
-			cmd := buildOpItemCommand("create", "--category=Login", "--title="+itemName(gitInputs["host"]), "--url="+gitInputs["protocol"]+"://"+gitInputs["host"], "username="+gitInputs["username"], "password="+gitInputs["password"])
-			output, err := cmd.CombinedOutput()
-			if err != nil {
-				log.Fatalf("op item create failed with %s %s", err, output)
-			}
-		} else {
-			// run "op create edit" command to update the item
-			cmd := buildOpItemCommand("edit", itemName(gitInputs["host"]), "--url="+gitInputs["protocol"]+"://"+gitInputs["host"], "username="+gitInputs["username"], "password="+gitInputs["password"])
-			output, err := cmd.CombinedOutput()
-			if err != nil {
-				log.Fatalf("op item edit failed with %s %s", err, output)
-			}
+		if cacheOnly {
+			// storing would trigger a prompt
+			return
+		}
+		if err := storeCredential(gitInputs); err != nil {
+			log.Fatal(err)
 		}
 	case "erase":
-		gitInputs := ReadLines()
-		// run "op delete item" command with the host value
-		buildOpItemCommand("delete", itemName(gitInputs["host"])).Run()
+		// the host can be given as argument for interactive use
+		var gitInputs Attributes
+		if len(args) > 1 {
+			gitInputs = Attributes{{"protocol", "https"}, {"host", args[1]}}
+		} else {
+			gitInputs = ReadLines()
+		}
+		if cacheOnly {
+			// erasing would trigger a prompt
+			return
+		}
+		if err := eraseCredential(gitInputs); err != nil {
+			log.Fatal(err)
+		}
+	case "signin":
+		if _, err := refreshSession(); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "daemon":
+		if err := runDaemon(); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "init":
+		if err := Init(); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "serve":
+		if err := Serve(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "explain":
+		if err := Explain(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "env":
+		if err := PrintEnv(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "import":
+		if err := ImportToken(args[1:]); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "reveal":
+		if err := Reveal(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "verify-protocol":
+		if err := VerifyProtocol(); err != nil {
+			log.Fatal(err)
+		}
+	case "add-host":
+		if err := AddHost(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "purge-sandbox":
+		if err := PurgeSandbox(); err != nil {
+			log.Fatal(err)
+		}
+	case "bench":
+		if err := Bench(args[1:]); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "lock":
+		if err := Lock(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "uninstall":
+		if err := Uninstall(args[1:]); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "vaults":
+		if err := PrintVaults(); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "accounts":
+		if err := PrintAccounts(); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "doctor":
+		Doctor()
+	case "prefetch":
+		if err := Prefetch(args[1:]); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "completion":
+		if err := PrintCompletion(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "__clear-clipboard":
+		ClearClipboard(args[1:])
+	case "__remove-secret-file":
+		if err := RemoveSecretFile(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "__complete":
+		Complete(args[1:])
+	case "conflicts":
+		if err := PrintConflicts(); err != nil {
+			log.Fatal(err)
+		}
+	case "header":
+		if err := PrintHeader(args[1:]); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "ssh-key":
+		if err := SSHKey(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "config":
+		if err := ConfigCommand(args[1:]); err != nil {
+			log.Fatal(err)
+		}
+	case "report":
+		if err := PrintReport(); err != nil {
+			log.Fatal(friendlyError(err))
+		}
+	case "stale":
+		if err := Stale(args[1:]); err != nil {
+			log.Fatal(friendlyError(err))
+		}
 	default:
 		// unknown argument
 		log.Fatalf("It doesn't look like anything to me. (Unknown argument: %s)\n", args[0])
@@ -0,0 +1,494 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testModeEnv makes the test binary act as the helper or as a fake op, so
+// the tests run the real binary like git does without building it first
+const testModeEnv = "GIT_CREDENTIAL_1PASSWORD_TEST"
+
+// fakeDBEnv is the path of the JSON file holding the items of the fake op
+const fakeDBEnv = "OP_FAKE_DB"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(testModeEnv) {
+	case "helper":
+		main()
+		os.Exit(0)
+	case "op":
+		os.Exit(fakeOp(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// fakeItem is an item of the fake op, it is printed by "item get" and,
+// without the fields, by "item list"
+type fakeItem struct {
+	ID        string      `json:"id"`
+	Title     string      `json:"title"`
+	Vault     OpVault     `json:"vault"`
+	Category  string      `json:"category,omitempty"`
+	Tags      []string    `json:"tags,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	URLs      []OpURL     `json:"urls,omitempty"`
+	Fields    []fakeField `json:"fields,omitempty"`
+}
+
+// fakeField is a field of an item of the fake op
+type fakeField struct {
+	ID      string `json:"id,omitempty"`
+	Label   string `json:"label,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// fakeDB is the account of the fake op
+type fakeDB struct {
+	Vaults []OpVault   `json:"vaults"`
+	Items  []*fakeItem `json:"items"`
+	NextID int         `json:"nextId"`
+}
+
+// fakeOpValueFlags are the flags of op the helper passes with a separate
+// value, all other flags are switches
+var fakeOpValueFlags = []string{"--account", "--vault", "--format", "--tags", "--categories", "--fields", "--session"}
+
+// fakeOp answers the op commands the helper runs from the database in
+// fakeDBEnv, errors use the wording of op, so the helper classifies them
+func fakeOp(args []string) int {
+	p := os.Getenv(fakeDBEnv)
+	var db fakeDB
+	raw, err := os.ReadFile(p)
+	if err == nil {
+		err = json.Unmarshal(raw, &db)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fake op: %s\n", err)
+		return 1
+	}
+	var positional []string
+	options := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(args[i], "=")
+		switch {
+		case !strings.HasPrefix(args[i], "--"):
+			positional = append(positional, args[i])
+		case hasValue:
+			options[name] = value
+		case contains(fakeOpValueFlags, name) && i+1 < len(args):
+			options[name] = args[i+1]
+			i++
+		default:
+			options[name] = ""
+		}
+	}
+	if _, ok := options["--version"]; ok {
+		fmt.Println("2.30.0")
+		return 0
+	}
+	if len(positional) == 0 {
+		fmt.Fprintln(os.Stderr, "fake op: missing command")
+		return 1
+	}
+	command := positional[0]
+	if len(positional) > 1 && positional[0] != "read" {
+		command += " " + positional[1]
+		positional = positional[2:]
+	} else {
+		positional = positional[1:]
+	}
+	stdin := func() []byte {
+		data, _ := io.ReadAll(os.Stdin)
+		return bytes.TrimSpace(data)
+	}
+	output, err := db.run(command, positional, options, stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] 2024/01/01 00:00:00 %s\n", err)
+		return 1
+	}
+	if output != nil {
+		if s, ok := output.(string); ok {
+			fmt.Print(s)
+		} else {
+			json.NewEncoder(os.Stdout).Encode(output)
+		}
+	}
+	raw, _ = json.MarshalIndent(db, "", "  ")
+	if err := os.WriteFile(p, raw, 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "fake op: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// run runs a command of the fake op and returns its output
+func (db *fakeDB) run(command string, args []string, options map[string]string, stdin func() []byte) (any, error) {
+	switch command {
+	case "whoami":
+		return map[string]string{"url": "my.1password.com", "email": "user@example.com"}, nil
+	case "account list":
+		return []map[string]string{{"url": "my.1password.com", "email": "user@example.com", "user_uuid": "USER"}}, nil
+	case "vault list":
+		return db.Vaults, nil
+	case "item list":
+		items, err := db.vaultItems(options["--vault"])
+		if err != nil {
+			return nil, err
+		}
+		list := []fakeItem{}
+		for _, item := range items {
+			if tag := options["--tags"]; tag != "" && !contains(item.Tags, tag) {
+				continue
+			}
+			listed := *item
+			listed.Fields = nil
+			list = append(list, listed)
+		}
+		return list, nil
+	case "item get":
+		return db.find(options["--vault"], args)
+	case "item create":
+		vault := db.Vaults[0]
+		if name := options["--vault"]; name != "" {
+			v, err := db.vault(name)
+			if err != nil {
+				return nil, err
+			}
+			vault = v
+		}
+		db.NextID++
+		item := &fakeItem{ID: fmt.Sprintf("item%022d", db.NextID), Title: options["--title"], Vault: vault, Category: "LOGIN", UpdatedAt: time.Now().UTC()}
+		if data := stdin(); len(data) > 0 {
+			if err := json.Unmarshal(data, item); err != nil {
+				return nil, err
+			}
+			item.ID, item.Vault = fmt.Sprintf("item%022d", db.NextID), vault
+		}
+		if options["--title"] != "" {
+			item.Title = options["--title"]
+		}
+		if href := options["--url"]; href != "" {
+			item.URLs = []OpURL{{Label: "website", Primary: true, Href: href}}
+		}
+		if tags := options["--tags"]; tags != "" {
+			item.Tags = strings.Split(tags, ",")
+		}
+		item.assign(args)
+		db.Items = append(db.Items, item)
+		return item, nil
+	case "item edit":
+		item, err := db.find(options["--vault"], args)
+		if err != nil {
+			return nil, err
+		}
+		if data := stdin(); len(data) > 0 {
+			id, vault := item.ID, item.Vault
+			if err := json.Unmarshal(data, item); err != nil {
+				return nil, err
+			}
+			item.ID, item.Vault = id, vault
+		}
+		item.assign(args[1:])
+		item.UpdatedAt = time.Now().UTC()
+		return item, nil
+	case "item delete":
+		item, err := db.find(options["--vault"], args)
+		if err != nil {
+			return nil, err
+		}
+		for i, other := range db.Items {
+			if other == item {
+				db.Items = append(db.Items[:i], db.Items[i+1:]...)
+				break
+			}
+		}
+		return nil, nil
+	case "read":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("a secret reference must be specified")
+		}
+		ref, _, _ := strings.Cut(strings.TrimPrefix(args[0], "op://"), "?")
+		parts := strings.Split(ref, "/")
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("invalid secret reference %q", args[0])
+		}
+		item, err := db.find(parts[0], parts[1:2])
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range item.Fields {
+			if field.ID == parts[2] || field.Label == parts[2] {
+				return field.Value, nil
+			}
+		}
+		return nil, fmt.Errorf("%q isn't a field in the %q item", parts[2], item.Title)
+	}
+	return nil, fmt.Errorf("unknown command %q for \"op\"", command)
+}
+
+// vault returns the vault with the id or name
+func (db *fakeDB) vault(name string) (OpVault, error) {
+	for _, v := range db.Vaults {
+		if v.ID == name || v.Name == name {
+			return v, nil
+		}
+	}
+	return OpVault{}, fmt.Errorf("%q isn't a vault in this account. Specify the vault with its ID or name.", name)
+}
+
+// vaultItems returns the items of the vault, all items without a vault
+func (db *fakeDB) vaultItems(name string) ([]*fakeItem, error) {
+	if name == "" {
+		return db.Items, nil
+	}
+	v, err := db.vault(name)
+	if err != nil {
+		return nil, err
+	}
+	var items []*fakeItem
+	for _, item := range db.Items {
+		if item.Vault.ID == v.ID {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// find returns the item with the id or title of the first argument
+func (db *fakeDB) find(vault string, args []string) (*fakeItem, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("an item must be specified")
+	}
+	items, err := db.vaultItems(vault)
+	if err != nil {
+		return nil, err
+	}
+	var found []*fakeItem
+	for _, item := range items {
+		if item.ID == args[0] || item.Title == args[0] {
+			found = append(found, item)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("%q isn't an item. Specify the item with its UUID, name, or domain.", args[0])
+	case 1:
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("More than one item matches %q. Try again and specify the item by its ID", args[0])
+}
+
+// assignmentPattern matches the assignment statements of op,
+// <field>[<type>]=<value>
+var assignmentPattern = regexp.MustCompile(`^([^=\[]+)(?:\[([a-z]+)\])?=`)
+
+// assign applies the assignment statements, the value is everything after
+// the first "=" unchanged
+func (item *fakeItem) assign(args []string) {
+	for _, arg := range args {
+		m := assignmentPattern.FindStringSubmatch(arg)
+		if m == nil {
+			continue
+		}
+		label, value := m[1], arg[len(m[0]):]
+		set := false
+		for i := range item.Fields {
+			if item.Fields[i].ID == label || item.Fields[i].Label == label {
+				item.Fields[i].Value, set = value, true
+			}
+		}
+		if set {
+			continue
+		}
+		field := fakeField{ID: label, Label: label, Type: "STRING", Value: value}
+		switch label {
+		case "username":
+			field.Purpose = "USERNAME"
+		case "password":
+			field.Type, field.Purpose = "CONCEALED", "PASSWORD"
+		}
+		item.Fields = append(item.Fields, field)
+	}
+}
+
+// helperTest runs git and the helper against a fake op in a temporary home
+type helperTest struct {
+	t      *testing.T
+	dir    string
+	config string
+	env    []string
+}
+
+// newHelperTest sets up git, the helper and the fake op with an empty
+// vault, cfg is the config file of the helper
+func newHelperTest(t *testing.T, cfg string) *helperTest {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	h := &helperTest{t: t, dir: dir, config: filepath.Join(dir, "config.json")}
+	bin := filepath.Join(dir, "bin")
+	if err := os.Mkdir(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	op := fmt.Sprintf("#!/bin/sh\n%s=op exec %s \"$@\"\n", testModeEnv, shellQuote(exe))
+	if err := os.WriteFile(filepath.Join(bin, "op"), []byte(op), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(h.config, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h.writeDB(fakeDB{Vaults: []OpVault{{ID: "vaultprivate00000000000000", Name: "Private"}}, Items: []*fakeItem{}})
+
+	helper := fmt.Sprintf("!%s=helper %s --config %s", testModeEnv, shellQuote(exe), shellQuote(h.config))
+	gitconfig := fmt.Sprintf("[credential]\n\thelper = %s\n", strconv.Quote(helper))
+	if err := os.WriteFile(filepath.Join(dir, "gitconfig"), []byte(gitconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the environment of the developer must not leak into the tests
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		switch {
+		case name == "PATH" || name == "HOME" || name == "CI":
+		case strings.HasPrefix(name, "GIT_"), strings.HasPrefix(name, "OP_"), strings.HasPrefix(name, "XDG_"),
+			strings.HasPrefix(name, "GITHUB_"), strings.HasPrefix(name, "GITLAB_"):
+		default:
+			h.env = append(h.env, kv)
+		}
+	}
+	h.env = append(h.env,
+		"PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"HOME="+dir,
+		homeEnv+"="+filepath.Join(dir, "home"),
+		"GIT_CONFIG_GLOBAL="+filepath.Join(dir, "gitconfig"),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_TERMINAL_PROMPT=0",
+		fakeDBEnv+"="+filepath.Join(dir, "db.json"),
+	)
+	return h
+}
+
+// run runs the command with the input and returns its output, the error
+// includes the standard error output
+func (h *helperTest) run(cmd *exec.Cmd, input string, env ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Dir = h.dir
+	cmd.Env = append(h.env, env...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("%s: %s\n%s", strings.Join(cmd.Args, " "), err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// git runs git with the input
+func (h *helperTest) git(input string, args ...string) (string, error) {
+	return h.run(exec.Command("git", args...), input)
+}
+
+// helper runs the helper directly with the input, like git does
+func (h *helperTest) helper(input string, args ...string) (string, error) {
+	h.t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return h.run(exec.Command(exe, append([]string{"--config", h.config}, args...)...), input, testModeEnv+"=helper")
+}
+
+// readDB returns the items of the fake op
+func (h *helperTest) readDB() fakeDB {
+	h.t.Helper()
+	var db fakeDB
+	raw, err := os.ReadFile(filepath.Join(h.dir, "db.json"))
+	if err == nil {
+		err = json.Unmarshal(raw, &db)
+	}
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return db
+}
+
+// writeDB replaces the items of the fake op
+func (h *helperTest) writeDB(db fakeDB) {
+	h.t.Helper()
+	raw, err := json.MarshalIndent(db, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(h.dir, "db.json"), raw, 0o600)
+	}
+	if err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// addItem adds a login item for the host to the fake op
+func (h *helperTest) addItem(title string, href string, username string, password string) {
+	h.t.Helper()
+	db := h.readDB()
+	db.NextID++
+	item := &fakeItem{ID: fmt.Sprintf("item%022d", db.NextID), Title: title, Vault: db.Vaults[0], Category: "LOGIN", UpdatedAt: time.Now().UTC()}
+	if href != "" {
+		item.URLs = []OpURL{{Label: "website", Primary: true, Href: href}}
+	}
+	item.assign([]string{"username=" + username, "password=" + password})
+	db.Items = append(db.Items, item)
+	h.writeDB(db)
+}
+
+// item returns the item with the title, nil if there is none
+func (h *helperTest) item(title string) *fakeItem {
+	h.t.Helper()
+	for _, item := range h.readDB().Items {
+		if item.Title == title {
+			return item
+		}
+	}
+	return nil
+}
+
+// field returns the value of the field of the item
+func (item *fakeItem) field(label string) string {
+	for _, field := range item.Fields {
+		if field.Label == label {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// requireGit skips the test if git is older than the version
+func requireGit(t *testing.T, version string) {
+	t.Helper()
+	out, err := exec.Command("git", "--version").Output()
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	installed := strings.Fields(string(out))
+	if len(installed) < 3 || compareVersions(installed[2], version) < 0 {
+		t.Skipf("requires git %s or later, found %s", version, strings.TrimSpace(string(out)))
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// isMultiline reports whether a value spans several lines, like a PEM key
+func isMultiline(value string) bool {
+	return strings.ContainsAny(value, "\r\n")
+}
+
+// opSetCredential updates the username and password of an existing item by
+// editing the item as json, all other fields and attributes are kept
+func opSetCredential(ref ItemRef, username string, password string) error {
+	if err := checkItemArg(ref.Item); err != nil {
+		return err
+	}
+	opItemRaw, err := runOp(buildOpItemCommand("get", ref.Vault, "--format", "json", ref.Item))
+	if err != nil {
+		return fmt.Errorf("opItemGet failed with %s", err)
+	}
+
+	// keep the raw item and fields so that no attributes get lost
+	var item map[string]json.RawMessage
+	if err = json.Unmarshal(opItemRaw, &item); err != nil {
+		return fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	var fields []map[string]any
+	if raw, ok := item["fields"]; ok {
+		if err = json.Unmarshal(raw, &fields); err != nil {
+			return fmt.Errorf("json.Unmarshal() failed with %s", err)
+		}
+	}
+	values := map[string]string{"USERNAME": username, "PASSWORD": password}
+	for _, field := range fields {
+		purpose, _ := field["purpose"].(string)
+		if purpose == "" {
+			// fields of e.g. Database items have no purpose
+			id, _ := field["id"].(string)
+			purpose = strings.ToUpper(id)
+		}
+		if value, ok := values[purpose]; ok {
+			field["value"] = value
+			delete(values, purpose)
+		}
+	}
+	if len(values) > 0 {
+		return fmt.Errorf("item %q has no username or password field", ref.Item)
+	}
+	if item["fields"], err = json.Marshal(fields); err != nil {
+		return err
+	}
+	template, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	// "op item edit" reads the edited item from stdin
+	opItemEdit := buildOpItemCommand("edit", ref.Vault, ref.Item)
+	opItemEdit.Stdin = bytes.NewReader(template)
+	_, err = runOp(opItemEdit)
+	return err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultOfflineCacheMaxAge is used when the config does not set
+// offlineCacheMaxAge
+const defaultOfflineCacheMaxAge = 72 * time.Hour
+
+// offlineCacheKey is the keyring entry of the encryption key
+const offlineCacheKey = "offline-cache-key"
+
+// OfflineEntry is a credential in the offline cache
+type OfflineEntry struct {
+	Username string    `json:"username"`
+	Password string    `json:"password"`
+	Fetched  time.Time `json:"fetched"`
+}
+
+// offlineCachePath returns the location of the encrypted offline cache
+func offlineCachePath() string {
+	return appFile(cacheDir, "offline.bin")
+}
+
+// offlineCipher returns the AEAD for the offline cache, the key is held in
+// the OS keyring and created on first use
+func offlineCipher(create bool) (cipher.AEAD, error) {
+	encoded, err := keyringGet(offlineCacheKey)
+	key, decodeErr := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || decodeErr != nil || len(key) != 32 {
+		if !create {
+			return nil, errors.New("no offline cache key")
+		}
+		key = make([]byte, 32)
+		if _, err = rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err = keyringSet(offlineCacheKey, base64.StdEncoding.EncodeToString(key)); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// loadOfflineCache decrypts the offline cache
+func loadOfflineCache(aead cipher.AEAD) map[string]OfflineEntry {
+	entries := make(map[string]OfflineEntry)
+	raw, err := os.ReadFile(offlineCachePath())
+	if err != nil || len(raw) < aead.NonceSize() {
+		return entries
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return entries
+	}
+	json.Unmarshal(plain, &entries)
+	return entries
+}
+
+// saveOffline stores the credential in the offline cache, expired entries
+// are dropped. The cache is updated under the lock of the file like the
+// state, so parallel invocations never lose each other's entries
+func saveOffline(key string, username string, password string) error {
+	aead, err := offlineCipher(true)
+	if err != nil {
+		return err
+	}
+	p := offlineCachePath()
+	if err = ensurePrivateDir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	unlock, err := lockFile(p + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries := loadOfflineCache(aead)
+	for k, entry := range entries {
+		if time.Since(entry.Fetched) > offlineMaxAge() {
+			delete(entries, k)
+		}
+	}
+	entries[key] = OfflineEntry{Username: username, Password: password, Fetched: time.Now()}
+
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+	return os.WriteFile(p, aead.Seal(nonce, nonce, plain, nil), 0o600)
+}
+
+// loadOffline returns a credential from the offline cache, unless it is
+// older than the max age
+func loadOffline(key string) (OfflineEntry, error) {
+	aead, err := offlineCipher(false)
+	if err != nil {
+		return OfflineEntry{}, err
+	}
+	entry, ok := loadOfflineCache(aead)[key]
+	if !ok {
+		return entry, fmt.Errorf("no offline credential for %s", key)
+	}
+	if time.Since(entry.Fetched) > offlineMaxAge() {
+		return entry, fmt.Errorf("offline credential for %s expired", key)
+	}
+	return entry, nil
+}
+
+// offlineMaxAge returns how long cached credentials may be served, the ttl
+// of the host takes precedence when it is shorter
+func offlineMaxAge() time.Duration {
+	maxAge := defaultOfflineCacheMaxAge
+	if config.OfflineCacheMaxAge != 0 {
+		maxAge = time.Duration(config.OfflineCacheMaxAge)
+	}
+	if credentialTTL != 0 && credentialTTL < maxAge {
+		return credentialTTL
+	}
+	return maxAge
+}
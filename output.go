@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"sync"
+)
+
+// colorEnabled reports whether escape sequences may be written to f: never
+// with NO_COLOR set, a dumb terminal or when the output is not a terminal,
+// so output consumed by scripts stays clean
+func colorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f)
+}
+
+var (
+	stdoutColor = sync.OnceValue(func() bool { return colorEnabled(os.Stdout) })
+	stderrColor = sync.OnceValue(func() bool { return colorEnabled(os.Stderr) })
+)
+
+// style wraps s in the SGR code if enabled
+func style(enabled bool, code string, s string) string {
+	if !enabled {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// text styles for standard output
+func bold(s string) string   { return style(stdoutColor(), "1", s) }
+func red(s string) string    { return style(stdoutColor(), "31", s) }
+func green(s string) string  { return style(stdoutColor(), "32", s) }
+func yellow(s string) string { return style(stdoutColor(), "33", s) }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appName is the name of the directories of the helper
+const appName = "git-credential-1password"
+
+// homeEnv overrides the base directory of all files of the helper, config,
+// state, cache and runtime files are kept in sub directories
+const homeEnv = "GIT_CREDENTIAL_1PASSWORD_HOME"
+
+// portableMarker next to the binary enables the portable mode like
+// --portable does
+const portableMarker = "git-credential-1password.portable"
+
+// portableDir keeps all files next to the binary when set
+var portableDir string
+
+// setupPortable enables the portable mode if requested by the flag or the
+// marker file, all files are kept in a directory next to the binary
+func setupPortable(flag bool) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	dir := filepath.Dir(exe)
+	if _, err := os.Stat(filepath.Join(dir, portableMarker)); err == nil || flag {
+		portableDir = filepath.Join(dir, appName+"-data")
+	}
+	return nil
+}
+
+// dirKind is a kind of directory of the XDG base directory specification
+type dirKind string
+
+const (
+	configDir  dirKind = "config"
+	stateDir   dirKind = "state"
+	cacheDir   dirKind = "cache"
+	runtimeDir dirKind = "runtime"
+)
+
+// xdgDir returns the XDG base directory of the kind, or "" if neither the
+// variable nor a default exists
+func xdgDir(kind dirKind) string {
+	var env, fallback string
+	switch kind {
+	case configDir:
+		env, fallback = "XDG_CONFIG_HOME", ".config"
+	case stateDir:
+		env, fallback = "XDG_STATE_HOME", filepath.Join(".local", "state")
+	case cacheDir:
+		env, fallback = "XDG_CACHE_HOME", ".cache"
+	case runtimeDir:
+		env = "XDG_RUNTIME_DIR"
+	}
+	// relative paths are invalid and must be ignored
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || fallback == "" {
+		return ""
+	}
+	return filepath.Join(home, fallback)
+}
+
+// appDir returns the directory of the helper for the kind of files: the XDG
+// base directories on Linux and BSD, Library/Application Support and
+// Library/Caches on macOS and %APPDATA% and %LOCALAPPDATA% on Windows.
+// Without a runtime directory, runtime files are kept with the cache
+func appDir(kind dirKind) string {
+	if portableDir != "" {
+		return filepath.Join(portableDir, string(kind))
+	}
+	if home := os.Getenv(homeEnv); home != "" {
+		return filepath.Join(home, string(kind))
+	}
+
+	var dir string
+	switch runtime.GOOS {
+	case "darwin", "windows", "ios", "plan9":
+		var err error
+		if kind == configDir || kind == stateDir {
+			dir, err = os.UserConfigDir()
+		} else {
+			dir, err = os.UserCacheDir()
+		}
+		if err != nil {
+			return ""
+		}
+	default:
+		if dir = xdgDir(kind); dir == "" && kind == runtimeDir {
+			dir = xdgDir(cacheDir)
+		}
+		if dir == "" {
+			return ""
+		}
+	}
+	return filepath.Join(dir, appName)
+}
+
+// appFile returns the path of a file of the helper, or "" if there is no
+// directory for the kind
+func appFile(kind dirKind, name string) string {
+	dir := appDir(kind)
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
+
+// migrateFile moves a file of an earlier version from the cache directory
+// to its current location, so upgrades keep the state
+func migrateFile(p string, name string) {
+	if p == "" || portableDir != "" || os.Getenv(homeEnv) != "" {
+		return
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return
+	}
+	old := filepath.Join(dir, appName, name)
+	if old == p {
+		return
+	}
+	if _, err := os.Stat(p); err == nil {
+		return
+	}
+	if _, err := os.Stat(old); err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(p), 0o700) == nil {
+		os.Rename(old, p)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// ensurePrivateDir creates the directory for files of the helper, the
+// profile directories on Windows are private to the user already
+func ensurePrivateDir(dir string) error {
+	return os.MkdirAll(dir, 0o700)
+}
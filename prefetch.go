@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ensureDaemon starts the daemon if none is running and waits until it
+// accepts connections
+func ensureDaemon() error {
+	if conn, err := net.Dial("unix", daemonSocketPath()); err == nil {
+		conn.Close()
+		return nil
+	}
+	startDaemon()
+	for i := 0; i < 40; i++ {
+		time.Sleep(50 * time.Millisecond)
+		if conn, err := net.Dial("unix", daemonSocketPath()); err == nil {
+			conn.Close()
+			return nil
+		}
+	}
+	return fmt.Errorf("the daemon did not start")
+}
+
+// gitConfigURLs returns the values of all url keys matching the regexp in
+// the git config of the repository, or of the given config file
+func gitConfigURLs(dir string, file string, pattern string) []string {
+	args := []string{"-C", dir, "config"}
+	if file != "" {
+		args = append(args, "-f", file)
+	}
+	args = append(args, "--get-regexp", pattern)
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil
+	}
+	var urls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if _, value, ok := strings.Cut(line, " "); ok {
+			urls = append(urls, value)
+		}
+	}
+	return urls
+}
+
+// repoHosts returns the hosts of all https remotes and submodules of the
+// repository in dir
+func repoHosts(dir string) []string {
+	urls := gitConfigURLs(dir, "", `^remote\..*\.url$`)
+	urls = append(urls, gitConfigURLs(dir, ".gitmodules", `^submodule\..*\.url$`)...)
+
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			continue
+		}
+		if !seen[u.Host] {
+			seen[u.Host] = true
+			hosts = append(hosts, u.Host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Prefetch resolves the credentials of all remotes and submodules of the
+// repository in one pass and primes the cache daemon with them, so a
+// recursive clone does not interleave many prompts
+func Prefetch(args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	hosts := repoHosts(dir)
+	if len(hosts) == 0 {
+		return fmt.Errorf("no https remotes or submodules found in %s", dir)
+	}
+
+	config.Daemon = true
+	if err := ensureDaemon(); err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		_, item, err := lookupRecentItem(vault, resolveHost(host), host, hostConfig(host).Username)
+		if err != nil {
+			fmt.Printf("%s\tnot found\n", host)
+			continue
+		}
+		fmt.Printf("%s\t%s\n", host, item.Title)
+	}
+	return nil
+}
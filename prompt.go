@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// confirm asks a yes/no question on the terminal, git uses stdin for the
+// credential protocol, so the terminal is opened directly. Without a
+// terminal the answer is no
+func confirm(question string) bool {
+	if ciMode {
+		return false
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// ask asks a question on the terminal and returns the answer, or def if the
+// answer is empty
+func ask(question string, def string) string {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return def
+	}
+	defer tty.Close()
+
+	if def != "" {
+		fmt.Fprintf(tty, "%s [%s] ", question, def)
+	} else {
+		fmt.Fprintf(tty, "%s ", question)
+	}
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def
+	}
+	return answer
+}
+
+// askSecret asks for a secret on the terminal without echoing it, the echo
+// is turned off with stty, so this needs a Unix terminal
+func askSecret(question string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to ask for the secret")
+	}
+	defer tty.Close()
+
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = tty
+		return cmd.Run()
+	}
+	if err := stty("-echo"); err != nil {
+		return "", fmt.Errorf("turning off the echo of the terminal failed with %s", err)
+	}
+	defer stty("echo")
+	fmt.Fprintf(tty, "%s ", question)
+	answer, _ := bufio.NewReader(tty).ReadString('\n')
+	fmt.Fprintln(tty)
+	return strings.TrimRight(answer, "\r\n"), nil
+}
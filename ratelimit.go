@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// rateLimitRetries is how often a rate limited command is retried
+	rateLimitRetries = 3
+	// rateLimitBackoff is the first pause after a rate limit error, it
+	// doubles with every consecutive error
+	rateLimitBackoff = 5 * time.Second
+	// maxRateLimitWait caps the pause of a single invocation
+	maxRateLimitWait = time.Minute
+)
+
+// RateLimit is the rate limit state shared by all invocations
+type RateLimit struct {
+	// Until is when op calls may be made again
+	Until time.Time `json:"until,omitempty"`
+	// Hits counts consecutive rate limit errors
+	Hits int `json:"hits,omitempty"`
+	// Last is when the last rate limit error occurred
+	Last time.Time `json:"last,omitempty"`
+}
+
+// rateLimitStatus matches a 429 HTTP status in the output of op, a bare
+// "429" could be part of an item id, title or vault name
+var rateLimitStatus = regexp.MustCompile(`(?i)\b(?:status(?: code)?|http/[\d.]+|code)\W{0,3}429\b`)
+
+// isRateLimited reports whether op failed because of the request rate limits
+// of 1Password service accounts
+func isRateLimited(err error) bool {
+	if code := opErrorCode(err); code != "" {
+		return code == "rate_limited"
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests") || rateLimitStatus.MatchString(msg)
+}
+
+// waitRateLimit pauses until the rate limit recorded by any invocation
+// is over
+func waitRateLimit() {
+	wait := time.Until(loadState().RateLimit.Until)
+	if wait > maxRateLimitWait {
+		wait = maxRateLimitWait
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// recordRateLimit records a rate limit error, so that other invocations pace
+// their requests as well, and returns how long to pause
+func recordRateLimit() time.Duration {
+	var backoff time.Duration
+	updateState(func(state *State) {
+		if time.Since(state.RateLimit.Last) > maxRateLimitWait {
+			state.RateLimit.Hits = 0
+		}
+		backoff = rateLimitBackoff << state.RateLimit.Hits
+		if backoff > maxRateLimitWait {
+			backoff = maxRateLimitWait
+		}
+		state.RateLimit.Hits++
+		state.RateLimit.Last = time.Now()
+		state.RateLimit.Until = time.Now().Add(backoff)
+	})
+	return backoff
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// remoteConfigTTL is how long a config read from 1Password is used before
+// it is read again, the config can not configure this itself
+const remoteConfigTTL = time.Hour
+
+// isRemoteConfig reports whether the config is read from a secret
+// reference like op://Shared/git-credential-config/config.json
+func isRemoteConfig(p string) bool {
+	return strings.HasPrefix(p, "op://")
+}
+
+// remoteConfigCachePath returns the location of the local copy of a config
+// read from 1Password, named after a hash of the reference
+func remoteConfigCachePath(ref string) string {
+	sum := sha256.Sum256([]byte(ref))
+	return appFile(cacheDir, "config-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// remoteConfigCaches returns the local copies of all configs read from
+// 1Password, not just the one of the current config
+func remoteConfigCaches() []string {
+	pattern := appFile(cacheDir, "config-*.json")
+	if pattern == "" {
+		return nil
+	}
+	paths, _ := filepath.Glob(pattern)
+	return paths
+}
+
+// readRemoteConfig reads the config from 1Password with "op read", the
+// local copy is used while it is fresh and when 1Password is unavailable.
+// With --no-disk no local copy is kept
+func readRemoteConfig(ref string) ([]byte, error) {
+	cache := ""
+	if !noDisk {
+		cache = remoteConfigCachePath(ref)
+	}
+	info, statErr := os.Stat(cache)
+	if statErr == nil && time.Since(info.ModTime()) < remoteConfigTTL {
+		if raw, err := os.ReadFile(cache); err == nil {
+			return raw, nil
+		}
+	}
+
+	raw, err := runOp(buildOpCommand("read", ref, "--no-newline"))
+	if err != nil {
+		if statErr == nil {
+			if cached, cacheErr := os.ReadFile(cache); cacheErr == nil {
+				warnf("reading the config from 1Password failed, using the copy from %s", info.ModTime().Format(time.RFC3339))
+				return cached, nil
+			}
+		}
+		return nil, fmt.Errorf("op read %s failed with %s", ref, friendlyError(err))
+	}
+	if cache != "" {
+		if err := ensurePrivateDir(filepath.Dir(cache)); err == nil {
+			os.WriteFile(cache, raw, 0o600)
+		}
+	}
+	return raw, nil
+}
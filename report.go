@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reportEntry is an item seen by at least one view of the report
+type reportEntry struct {
+	item   OpListItem
+	views  []string
+	issues []string
+}
+
+// titleHost returns the host an item is named after, titles without the
+// prefix or not looking like a host name none
+func titleHost(item OpListItem) (string, bool) {
+	title, ok := strings.CutPrefix(item.Title, prefix)
+	if !ok {
+		return "", false
+	}
+	title, _, _ = strings.Cut(title, titleSeparator)
+	if title == "" || strings.ContainsAny(title, " \t") || checkHost(title) != nil || !strings.ContainsAny(title, ".:") {
+		return "", false
+	}
+	return strings.ToLower(title), true
+}
+
+// hasTag reports whether the item carries the tag
+func (i OpListItem) hasTag(tag string) bool {
+	return tag != "" && contains(i.Tags, tag)
+}
+
+// knownHosts returns the hosts the helper serves: hosts items are named
+// after, hosts remembered in the state and hosts with settings
+func knownHosts(items []OpListItem) map[string]bool {
+	hosts := make(map[string]bool)
+	for _, item := range items {
+		if host, ok := titleHost(item); ok {
+			hosts[host] = true
+		}
+	}
+	for key := range loadState().Items {
+		if i := strings.LastIndex(key, "@"); i >= 0 {
+			hosts[strings.ToLower(key[i+1:])] = true
+		}
+	}
+	for pattern := range config.Hosts {
+		if !strings.ContainsAny(pattern, "*?[") {
+			hosts[strings.ToLower(pattern)] = true
+		}
+	}
+	return hosts
+}
+
+// buildReport cross-references the items found by title, by tag and by
+// website and collects inconsistencies between the views
+func buildReport(items []OpListItem) []reportEntry {
+	hosts := knownHosts(items)
+	var entries []reportEntry
+	for _, item := range items {
+		entry := reportEntry{item: item}
+		host, byTitle := titleHost(item)
+		byTag := item.hasTag(config.Tag)
+		var urlHosts []string
+		for _, u := range item.URLs {
+			urlHosts = append(urlHosts, strings.ToLower(urlHost(u.Href)))
+		}
+		byURL := false
+		for _, h := range urlHosts {
+			byURL = byURL || hosts[h]
+		}
+		for view, ok := range map[string]bool{"title": byTitle, "tag": byTag, "url": byURL} {
+			if ok {
+				entry.views = append(entry.views, view)
+			}
+		}
+		if len(entry.views) == 0 {
+			continue
+		}
+		sort.Strings(entry.views)
+
+		if config.Tag != "" {
+			switch {
+			case byTag && !byTitle:
+				entry.issues = append(entry.issues, fmt.Sprintf("tagged %q but the title names no host", config.Tag))
+			case byTitle && !byTag:
+				entry.issues = append(entry.issues, fmt.Sprintf("named after %s but not tagged %q", host, config.Tag))
+			case byURL && !byTag:
+				entry.issues = append(entry.issues, fmt.Sprintf("website matches a git host but not tagged %q", config.Tag))
+			}
+		}
+		if byTitle && len(urlHosts) > 0 && !contains(urlHosts, host) {
+			entry.issues = append(entry.issues, fmt.Sprintf("named after %s but its websites point to %s", host, strings.Join(urlHosts, ", ")))
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].item.Title) < strings.ToLower(entries[j].item.Title)
+	})
+	return entries
+}
+
+// PrintReport prints an inventory of the items of the helper as seen by the
+// title prefix, the tag and the websites, with the inconsistencies found
+func PrintReport() error {
+	vaults := []string{vault}
+	if config.SearchVaults {
+		vaults = append(vaults, searchVaults(vault)...)
+	}
+	var items []OpListItem
+	for _, v := range vaults {
+		found, err := opListItems(v)
+		if err != nil {
+			return err
+		}
+		items = append(items, found...)
+	}
+
+	entries := buildReport(items)
+	issues := 0
+	for _, entry := range entries {
+		fmt.Printf("%s\tvault=%s\tviews=%s\tid=%s\n", bold(entry.item.Title), entry.item.Vault.Name, strings.Join(entry.views, ","), entry.item.ID)
+		for _, issue := range entry.issues {
+			fmt.Printf("  %s %s\n", yellow("warning:"), issue)
+		}
+		issues += len(entry.issues)
+	}
+	fmt.Printf("%d items, %d inconsistencies\n", len(entries), issues)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// responseWriter writes the answers of the helper to git or a client, every
+// response is validated and buffered completely before it is written with a
+// single write, so a failure never leaves a partial response and nothing is
+// left in a buffer when the process exits
+type responseWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// newResponseWriter returns a response writer for w, e.g. os.Stdout
+func newResponseWriter(w io.Writer) *responseWriter {
+	return &responseWriter{w: w}
+}
+
+// checkAttr rejects keys and values which would corrupt the protocol, a
+// newline or NUL in a value or a "=" in a key, multi-line secrets can only
+// be served in other formats
+func checkAttr(key string, value string) error {
+	if key == "" || strings.ContainsAny(key, "=\n\x00") {
+		return fmt.Errorf("invalid attribute name %q", key)
+	}
+	if strings.ContainsAny(value, "\n\x00") {
+		return fmt.Errorf("the %s contains a newline or NUL character, which git does not support, multi-line secrets are available with `env` and `serve`", key)
+	}
+	return nil
+}
+
+// WriteAttributes writes the attributes in their order followed by the
+// line git reads up to, nothing is written if any attribute is invalid
+func (rw *responseWriter) WriteAttributes(attrs Attributes) error {
+	var buf bytes.Buffer
+	for _, attr := range attrs {
+		if err := checkAttr(attr.Key, attr.Value); err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", attr.Key, attr.Value)
+	}
+	return rw.write(buf.Bytes())
+}
+
+// WriteJSON writes v as a single line of json
+func (rw *responseWriter) WriteJSON(v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return rw.write(append(raw, '\n'))
+}
+
+// write writes a complete response, concurrent responses never interleave
+func (rw *responseWriter) write(p []byte) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if _, err := rw.w.Write(p); err != nil {
+		return err
+	}
+	// a buffered writer is flushed right away, git waits for the response
+	if f, ok := rw.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// attributes returns the credential as attributes in the order git expects
+// them: the capabilities the response relies on first, then the credential
+// and its properties
+func (c *Credential) attributes() Attributes {
+	var attrs Attributes
+	if c.AuthType != "" {
+		attrs = append(attrs, Attribute{"capability[]", "authtype"}, Attribute{"authtype", c.AuthType}, Attribute{"credential", c.Password})
+	} else {
+		attrs = append(attrs, Attribute{"username", c.Username}, Attribute{"password", c.Password})
+	}
+	if c.Ephemeral {
+		attrs = append(attrs, Attribute{"ephemeral", "1"})
+	}
+	return attrs
+}
+
+// knownAttributes are the attributes of the credential protocol the helper
+// reads or answers itself
+var knownAttributes = []string{
+	"protocol", "host", "path", "url", "username", "password", "password_expiry_utc", "oauth_refresh_token",
+	"wwwauth[]", "capability[]", "authtype", "credential", "ephemeral", "state[]", "continue",
+}
+
+// unknownAttributes returns the attributes of the request the helper does
+// not know, they are echoed back unchanged after the credential, so tools
+// that run the helper with their own attributes (e.g. git svn or deployment
+// scripts) get them back as the protocol intends
+func unknownAttributes(gitInputs Attributes) Attributes {
+	var attrs Attributes
+	for _, attr := range gitInputs {
+		if !contains(knownAttributes, attr.Key) {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Reveal prints the stored credential of a host after an explicit
+// confirmation on the terminal, with --copy the password is put on the
+// clipboard instead of printing it
+func Reveal(args []string) error {
+	flags := flag.NewFlagSet("reveal", flag.ExitOnError)
+	copyPassword := flags.Bool("copy", false, "Copy the password to the clipboard instead of printing it")
+	clearAfter := flags.Duration("clear-after", defaultClipboardClear, "Clear the clipboard after this duration")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: git credential-1password reveal [--copy] [--clear-after <duration>] <host>")
+	}
+	requestHost := flags.Arg(0)
+	if err := checkHost(requestHost); err != nil {
+		return err
+	}
+	if !confirm(fmt.Sprintf("Reveal the credential of %s?", requestHost)) {
+		return fmt.Errorf("reveal aborted")
+	}
+
+	// always ask op, so 1Password asks for authorization if it is locked
+	// instead of the daemon answering from its cache
+	config.Daemon = false
+
+	hc := hostConfig(requestHost)
+	_, item, err := lookupRecentItem(vault, resolveHost(requestHost), requestHost, hc.Username)
+	if err != nil {
+		return friendlyError(err)
+	}
+	username := item.Fields.GetField("username")
+	if hc.Username != "" {
+		username = hc.Username
+	}
+	fmt.Printf("username: %s\n", username)
+	password, _ := item.Fields.resolvePassword(hc)
+	if *copyPassword {
+		if err := copySecret(password, *clearAfter); err != nil {
+			return err
+		}
+		fmt.Printf("password: copied to the clipboard, cleared in %s\n", *clearAfter)
+		return nil
+	}
+	fmt.Printf("password: %s\n", password)
+	return nil
+}
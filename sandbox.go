@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sandboxVault confines the helper to a dedicated vault for automated tests
+// of the helper itself, set with --sandbox-vault
+var sandboxVault string
+
+// sandboxTag marks the items created in the sandbox, purge-sandbox deletes
+// only these
+const sandboxTag = "git-credential-1password-sandbox"
+
+// personalVaults are the names of the default vault of an account, which
+// hold the real credentials of the user
+var personalVaults = []string{"private", "personal", "employee"}
+
+// enterSandbox switches to the sandbox vault with the default settings,
+// only the settings needed to reach op are kept, so no rule, alias or
+// cache can lead to an item outside of the sandbox
+func enterSandbox() error {
+	if contains(personalVaults, strings.ToLower(sandboxVault)) {
+		return fmt.Errorf("refusing to use the %s vault as sandbox, create a dedicated vault for tests", sandboxVault)
+	}
+	vault = sandboxVault
+	config = Config{
+		Backends:       config.Backends,
+		SessionKeyring: config.SessionKeyring,
+		OpArgs:         config.OpArgs,
+		PassEnv:        config.PassEnv,
+		Env:            config.Env,
+		Tag:            sandboxTag,
+	}
+	return nil
+}
+
+// PurgeSandbox deletes all items the helper created in the sandbox vault
+// and the state of the sandbox
+func PurgeSandbox() error {
+	if sandboxVault == "" {
+		return fmt.Errorf("usage: git credential-1password --sandbox-vault=<vault> purge-sandbox")
+	}
+	raw, err := runOp(buildOpItemCommand("list", vault, "--tags", sandboxTag, "--format", "json"))
+	if err != nil {
+		return fmt.Errorf("opItemList failed with %s", err)
+	}
+	var items []OpListItem
+	if err = json.Unmarshal(raw, &items); err != nil {
+		return fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	for _, item := range items {
+		if _, err := runOp(buildOpItemCommand("delete", item.Vault.ID, item.ID)); err != nil {
+			return fmt.Errorf("deleting %q failed with %s", item.Title, friendlyError(err))
+		}
+	}
+	os.Remove(statePath())
+	fmt.Printf("Deleted %d items from %s\n", len(items), sandboxVault)
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// noDisk refuses features that need secrets in files on a persistent disk
+var noDisk bool
+
+// writeSecretFile writes secret material that another program needs as a
+// file, e.g. a client certificate, to a private directory in memory if the
+// system has one. The returned function shreds and removes the file
+func writeSecretFile(name string, data []byte) (string, func(), error) {
+	base := memoryDir()
+	if base == "" {
+		if noDisk {
+			return "", nil, fmt.Errorf("%s can not be written without a memory-backed directory, refused by --no-disk", name)
+		}
+		if base = appDir(runtimeDir); base == "" {
+			return "", nil, fmt.Errorf("no directory for %s", name)
+		}
+		if err := ensurePrivateDir(base); err != nil {
+			return "", nil, err
+		}
+	}
+	dir, err := os.MkdirTemp(base, appName+"-*")
+	if err != nil {
+		return "", nil, err
+	}
+	p := filepath.Join(dir, name)
+	cleanup := func() {
+		shredFile(p)
+		os.RemoveAll(dir)
+	}
+	if err = os.WriteFile(p, data, 0o600); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return p, cleanup, nil
+}
+
+// isSecretFileDir reports whether dir is a private directory created by
+// writeSecretFile, directly in the memory or runtime directory and named
+// after the helper
+func isSecretFileDir(dir string) bool {
+	base := memoryDir()
+	if base == "" {
+		base = appDir(runtimeDir)
+	}
+	if base == "" || !filepath.IsAbs(dir) {
+		return false
+	}
+	dir = filepath.Clean(dir)
+	if filepath.Dir(dir) != filepath.Clean(base) || !strings.HasPrefix(filepath.Base(dir), appName+"-") {
+		return false
+	}
+	info, err := os.Lstat(dir)
+	return err == nil && info.IsDir()
+}
+
+// shredFile overwrites the file with zeros before it is removed, this is
+// best effort only, copy-on-write file systems keep the old blocks
+func shredFile(p string) {
+	f, err := os.OpenFile(p, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil {
+		f.Write(make([]byte, info.Size()))
+		f.Sync()
+	}
+}
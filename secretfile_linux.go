@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// tmpfsMagic is the file system type of tmpfs
+const tmpfsMagic = 0x01021994
+
+// memoryDir returns a memory-backed directory for secret files, the
+// runtime directory of the user is preferred over the shared /dev/shm
+func memoryDir() string {
+	for _, dir := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		var fs syscall.Statfs_t
+		if dir != "" && syscall.Statfs(dir, &fs) == nil && fs.Type == tmpfsMagic {
+			return dir
+		}
+	}
+	return ""
+}
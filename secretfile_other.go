@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// memoryDir returns a memory-backed directory for secret files, only Linux
+// has one for every user
+func memoryDir() string {
+	return ""
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// rpcRequest is a JSON-RPC 2.0 request, requests without an id are
+// notifications and get no response
+type rpcRequest struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      json.RawMessage   `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+// rpcError is the error object of a JSON-RPC response
+type rpcError struct {
+	Code    int            `json:"code"`
+	Message string         `json:"message"`
+	Data    *ErrorEnvelope `json:"data,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcServerError    = -32000
+)
+
+// ListedItem is a credential in the result of list
+type ListedItem struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title"`
+	Vault string   `json:"vault"`
+	Hosts []string `json:"hosts"`
+}
+
+// Explanation is the result of explain, it shows how a request is resolved
+// to an item without touching 1Password
+type Explanation struct {
+	RequestHost    string   `json:"requestHost"`
+	Host           string   `json:"host"`
+	Rule           bool     `json:"rule"`
+	Item           ItemRef  `json:"item"`
+	Recent         *ItemRef `json:"recent,omitempty"`
+	PasswordFields []string `json:"passwordFields"`
+	// PasswordField is the field the password was found in, it is only
+	// resolved with the param "fields=1" as this reads the item
+	PasswordField string `json:"passwordField,omitempty"`
+}
+
+// explainRequest resolves the request like get does, op is only called
+// with "fields=1" to show which field the password is taken from
+func explainRequest(inputs Attributes) (*Explanation, error) {
+	if err := checkHost(inputs.Get("host")); err != nil {
+		return nil, err
+	}
+	requestHost := routeHost(inputs)
+	username := inputs.Get("username")
+	if username == "" {
+		username = hostConfig(requestHost).Username
+	}
+	host := resolveHost(requestHost)
+	hc := hostConfig(requestHost)
+	_, rule := matchRule(host)
+	explanation := &Explanation{RequestHost: requestHost, Host: host, Rule: rule, Item: itemRef(vault, host, username), PasswordFields: passwordFields(hc)}
+	if ref, ok := loadState().Items[stateKey(requestHost, username)]; ok {
+		explanation.Recent = &ref
+	}
+	if gitBool(inputs.Get("fields")) {
+		_, item, err := lookupRecentItem(vault, host, requestHost, username)
+		if err != nil {
+			return nil, friendlyError(err)
+		}
+		_, explanation.PasswordField = item.Fields.resolvePassword(hc)
+	}
+	return explanation, nil
+}
+
+// Explain prints how a request for the host resolves to an item, like the
+// explain method of serve
+func Explain(args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	fields := flags.Bool("fields", false, "Read the item and show the field the password is taken from")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: git credential-1password explain [--fields] <host>")
+	}
+	params := map[string]string{"protocol": "https", "host": flags.Arg(0)}
+	if *fields {
+		params["fields"] = "1"
+	}
+	explanation, err := explainRequest(attributesOf(params))
+	if err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(explanation, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(raw))
+	return nil
+}
+
+// listItems returns the items of the vault with their hosts
+func listItems() ([]ListedItem, error) {
+	items, err := opListItems(vault)
+	if err != nil {
+		return nil, err
+	}
+	listed := make([]ListedItem, 0, len(items))
+	for _, item := range items {
+		listed = append(listed, ListedItem{ID: item.ID, Title: item.Title, Vault: item.Vault.Name, Hosts: itemHosts(item)})
+	}
+	return listed, nil
+}
+
+// handleRPC runs a single request
+func handleRPC(req rpcRequest) (any, error) {
+	switch req.Method {
+	case "get":
+		return getCredential(attributesOf(req.Params))
+	case "store":
+		return nil, storeCredential(attributesOf(req.Params))
+	case "erase":
+		return nil, eraseCredential(attributesOf(req.Params))
+	case "list":
+		return listItems()
+	case "explain":
+		return explainRequest(attributesOf(req.Params))
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", req.Method)}
+}
+
+// Error returns the message of the error object
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// Serve keeps the helper running for an editor or GUI client and answers
+// JSON-RPC requests, one per line, on stdin and stdout
+func Serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	stdio := flags.Bool("stdio", false, "Speak JSON-RPC on stdin and stdout")
+	flags.Parse(args)
+	if !*stdio {
+		return fmt.Errorf("usage: git credential-1password serve --stdio")
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(os.Stdin))
+	responses := newResponseWriter(os.Stdout)
+	for {
+		var req rpcRequest
+		if err := decoder.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) {
+				// the stream can not be resynchronized after a parse error
+				responses.WriteJSON(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}})
+			}
+			return nil
+		}
+
+		result, err := handleRPC(req)
+		if len(req.ID) == 0 {
+			continue
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+		if result == nil {
+			resp.Result = json.RawMessage("null")
+		}
+		if err != nil {
+			rpcErr, ok := err.(*rpcError)
+			if !ok {
+				envelope := errorEnvelope(err)
+				rpcErr = &rpcError{Code: rpcServerError, Message: envelope.Message, Data: envelope}
+			}
+			resp.Result, resp.Error = nil, rpcErr
+		}
+		if err := responses.WriteJSON(resp); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// sessionKey returns the keyring entry of the session of the account
+func sessionKey() string {
+	return "session:" + account
+}
+
+// hasSessionEnv reports whether the user exported a session already
+func hasSessionEnv() bool {
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, "OP_SESSION_") {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// sessionMu guards sessionEnv, the daemon reloads the session while
+	// serving requests
+	sessionMu sync.Mutex
+	// sessionEnv is the OP_SESSION_ variable with the session of the
+	// keyring, op reads it from its environment so the session never shows
+	// up in the process list
+	sessionEnv string
+)
+
+// sessionEnvName returns the variable op reads the session of the account
+// from, OP_SESSION_ followed by the user id of the account
+func sessionEnvName() string {
+	name := account
+	if accounts, err := discoverAccounts(); err == nil {
+		for _, a := range accounts {
+			if account == "" && len(accounts) == 1 || account != "" && (account == a.UserUUID || account == a.AccountUUID ||
+				strings.EqualFold(account, a.Email) || strings.EqualFold(account, a.URL) || strings.EqualFold(account+".1password.com", a.URL)) {
+				name = a.UserUUID
+				break
+			}
+		}
+	}
+	return "OP_SESSION_" + name
+}
+
+// setSession passes the session to op from now on
+func setSession(session string) {
+	name := sessionEnvName()
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	sessionEnv = name + "=" + session
+}
+
+// currentSessionEnv returns the OP_SESSION_ variable of the keyring session,
+// empty without one
+func currentSessionEnv() string {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return sessionEnv
+}
+
+// loadSession passes the session stored in the keyring to op, unless the
+// user exported one
+func loadSession() {
+	if hasSessionEnv() {
+		return
+	}
+	if session, err := keyringGet(sessionKey()); err == nil && session != "" {
+		setSession(session)
+	}
+}
+
+// isSessionError reports whether op failed because of a missing or expired
+// session
+func isSessionError(err error) bool {
+	if code := opErrorCode(err); code != "" {
+		return code == "not_signed_in"
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "not currently signed in") || strings.Contains(msg, "session expired") || strings.Contains(msg, "invalid session")
+}
+
+// refreshSession signs in with "op signin --raw" and stores the new session
+// in the keyring, op asks for the password on the terminal
+func refreshSession() (string, error) {
+	args := []string{"signin", "--raw"}
+	if account != "" {
+		args = append(args, "--account", account)
+	}
+	args = append(args, opArgs...)
+	cmd := exec.Command("op", args...)
+	cmd.Env = opEnv()
+	// git uses stdin for the credential protocol, so op needs the terminal
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		defer tty.Close()
+		cmd.Stdin = tty
+	}
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("op signin failed with %s", err)
+	}
+	session := strings.TrimSpace(string(output))
+	if err = keyringSet(sessionKey(), session); err != nil {
+		return "", err
+	}
+	setSession(session)
+	return session, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+// shapeTokenInUsername sends the token as username, with the fixed password
+// of the host or an empty one
+const shapeTokenInUsername = "token-in-username"
+
+// shapeTokenInPassword sends the token as password, with the username of
+// the item, of git or an empty one for servers accepting any username
+const shapeTokenInPassword = "token-in-password"
+
+// shapes are the valid values of the shape setting
+var shapes = []string{shapeTokenInUsername, shapeTokenInPassword}
+
+// shapeCredential turns the username and password of the item into the
+// layout the host expects
+func shapeCredential(hc HostConfig, username string, password string) (string, string) {
+	if hc.Shape == shapeTokenInUsername {
+		return password, hc.Password
+	}
+	return username, password
+}
+
+// unshapeCredential turns the credential git approved back into the username
+// and password of the item
+func unshapeCredential(hc HostConfig, inputs Attributes) Attributes {
+	if hc.Shape != shapeTokenInUsername {
+		return inputs
+	}
+	return inputs.Set("username", "").Set("password", inputs.Get("username"))
+}
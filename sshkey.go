@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSSHKeyLifetime is how long a key stays in the agent or on disk
+const defaultSSHKeyLifetime = time.Hour
+
+// sshPrivateKey reads the private key of the host in OpenSSH format, from
+// the SSH Key item of the sshKey setting or the item titled like the host
+func sshPrivateKey(host string) (string, error) {
+	ref := itemRef(vault, resolveHost(host), "")
+	if key := hostConfig(host).SSHKey; key != "" {
+		ref = parseItemRef(key)
+		if ref.Vault == "" {
+			ref.Vault = vault
+		}
+	}
+	item, err := opGetItem(ref)
+	if err != nil {
+		return "", friendlyError(err)
+	}
+	for _, f := range item.Fields {
+		if f.ID == "private_key" && f.Reference != "" {
+			// op converts the PKCS#8 key it stores for ssh on request
+			key, err := runOp(buildOpCommand("read", f.Reference+"?ssh-format=openssh"))
+			if err != nil {
+				return "", fmt.Errorf("op read failed with %s", friendlyError(err))
+			}
+			return strings.TrimRight(string(key), "\n") + "\n", nil
+		}
+	}
+	return "", fmt.Errorf("item %q has no private key, is it an SSH Key item?", item.Title)
+}
+
+// addToAgent loads the key into the running ssh-agent for the lifetime, the
+// key is passed on stdin and never written to a file
+func addToAgent(key string, lifetime time.Duration) error {
+	if os.Getenv("SSH_AUTH_SOCK") == "" {
+		return fmt.Errorf("no ssh-agent is running, use --file to write the key to a file instead")
+	}
+	cmd := exec.Command("ssh-add", "-q", "-t", strconv.Itoa(int(lifetime.Seconds())), "-")
+	cmd.Stdin = strings.NewReader(key)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ssh-add failed with %s %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// SSHKey makes the deploy key of a host available to ssh: it is added to
+// the ssh-agent, or with --file written to a private file in memory whose
+// path is printed for IdentityFile or GIT_SSH_COMMAND
+func SSHKey(args []string) error {
+	flags := flag.NewFlagSet("ssh-key", flag.ExitOnError)
+	toFile := flags.Bool("file", false, "Write the key to a private file and print its path instead of using the ssh-agent")
+	lifetime := flags.Duration("lifetime", defaultSSHKeyLifetime, "Remove the key from the agent or the file after this duration")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: git credential-1password ssh-key [--file] [--lifetime <duration>] <host>")
+	}
+	host := flags.Arg(0)
+	if err := checkHost(host); err != nil {
+		return err
+	}
+	if *lifetime < time.Second {
+		return fmt.Errorf("invalid lifetime %s", *lifetime)
+	}
+	key, err := sshPrivateKey(host)
+	if err != nil {
+		return err
+	}
+
+	if !*toFile {
+		if err := addToAgent(key, *lifetime); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Added the key of %s to the ssh-agent for %s\n", host, *lifetime)
+		return nil
+	}
+
+	p, cleanup, err := writeSecretFile("id_"+envNameInvalid.ReplaceAllString(host, "_"), []byte(key))
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		// the child has to find the directory of the file where this
+		// process put it
+		var args []string
+		if portableDir != "" {
+			args = append(args, "--portable")
+		}
+		if noDisk {
+			args = append(args, "--no-disk")
+		}
+		args = append(args, "__remove-secret-file", lifetime.String(), p)
+		cmd := exec.Command(exe, args...)
+		detach(cmd)
+		if err = cmd.Start(); err == nil {
+			err = cmd.Process.Release()
+		}
+	}
+	if err != nil {
+		cleanup()
+		return err
+	}
+	fmt.Println(p)
+	return nil
+}
+
+// RemoveSecretFile waits and shreds a file written by writeSecretFile, any
+// other path is refused
+func RemoveSecretFile(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: __remove-secret-file <after> <file>")
+	}
+	after, err := time.ParseDuration(args[0])
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(args[1])
+	if !isSecretFileDir(dir) {
+		return fmt.Errorf("refusing to remove %s, it was not written by the helper", args[1])
+	}
+	time.Sleep(after)
+	shredFile(args[1])
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// servedResolution limits the writes of the state file to one per item
+// and hour, the last use does not need to be more precise
+const servedResolution = time.Hour
+
+// recordServed remembers when the item was last served to git
+func recordServed(id string) {
+	if id == "" {
+		return
+	}
+	now := time.Now()
+	if now.Sub(loadState().Served[id]) < servedResolution {
+		return
+	}
+	updateState(func(state *State) {
+		if state.Served == nil {
+			state.Served = make(map[string]time.Time)
+		}
+		if state.TrackedSince.IsZero() {
+			state.TrackedSince = now
+		}
+		state.Served[id] = now
+	})
+}
+
+// parseAge parses a duration like time.ParseDuration and whole days like
+// "90d"
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// staleItems returns the items of the helper, named after a host or tagged,
+// that were not served since the cutoff. Items never served count as served
+// when the tracking started
+func staleItems(items []OpListItem, cutoff time.Time) []OpListItem {
+	state := loadState()
+	var stale []OpListItem
+	for _, item := range items {
+		if _, ok := titleHost(item); !ok && !item.hasTag(config.Tag) {
+			continue
+		}
+		served, ok := state.Served[item.ID]
+		if !ok {
+			served = state.TrackedSince
+		}
+		if !served.IsZero() && served.Before(cutoff) {
+			stale = append(stale, item)
+		}
+	}
+	return stale
+}
+
+// Stale lists the credentials of the helper that have not been used for a
+// long time and optionally archives them
+func Stale(args []string) error {
+	flags := flag.NewFlagSet("stale", flag.ExitOnError)
+	olderThan := flags.String("older-than", "90d", "List credentials not used for this duration, e.g. 90d or 720h")
+	archive := flags.Bool("archive", false, "Archive the stale items")
+	yes := flags.Bool("yes", false, "Do not ask for confirmation")
+	flags.Parse(args)
+	age, err := parseAge(*olderThan)
+	if err != nil {
+		return err
+	}
+
+	vaults := []string{vault}
+	if config.SearchVaults {
+		vaults = append(vaults, searchVaults(vault)...)
+	}
+	var items []OpListItem
+	for _, v := range vaults {
+		found, err := opListItems(v)
+		if err != nil {
+			return err
+		}
+		items = append(items, found...)
+	}
+
+	state := loadState()
+	stale := staleItems(items, time.Now().Add(-age))
+	for _, item := range stale {
+		lastServed := "never since " + state.TrackedSince.Format(time.DateOnly)
+		if served, ok := state.Served[item.ID]; ok {
+			lastServed = served.Format(time.DateOnly)
+		}
+		fmt.Printf("%s\tvault=%s\tlast used=%s\tid=%s\n", item.Title, item.Vault.Name, lastServed, item.ID)
+	}
+	if len(stale) == 0 {
+		fmt.Printf("no credentials unused for %s found\n", *olderThan)
+		return nil
+	}
+	if !*archive || !*yes && !confirm(fmt.Sprintf("Archive %d items?", len(stale))) {
+		return nil
+	}
+	return archiveItems(stale)
+}
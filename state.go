@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State is persisted between invocations of the helper, it only holds
+// references to items and never any secrets
+type State struct {
+	// Items maps "username@host" of a request to the item used last for it
+	Items map[string]ItemRef `json:"items,omitempty"`
+
+	// Vaults maps the host of a request to the vault its item was found in
+	Vaults map[string]string `json:"vaults,omitempty"`
+
+	// Discovery caches the vaults per account
+	Discovery map[string]Discovery `json:"discovery,omitempty"`
+
+	// Accounts caches the accounts configured in op
+	Accounts AccountDiscovery `json:"accounts,omitempty"`
+
+	// RateLimit paces op calls after rate limit errors
+	RateLimit RateLimit `json:"rateLimit,omitempty"`
+
+	// Served maps the id of an item to when it was last served to git
+	Served map[string]time.Time `json:"served,omitempty"`
+
+	// TrackedSince is when the last use of items was first recorded
+	TrackedSince time.Time `json:"trackedSince,omitempty"`
+
+	// Backends tracks the health of the configured backends by name
+	Backends map[string]BackendHealth `json:"backends,omitempty"`
+
+	// Created holds when items were created in the last hour
+	Created []time.Time `json:"created,omitempty"`
+
+	// DatabaseURLs maps the id of a Database item to the website entries
+	// derived from its server
+	DatabaseURLs map[string]DatabaseURLs `json:"databaseUrls,omitempty"`
+
+	// Acknowledged is when the summary of the first run was shown
+	Acknowledged time.Time `json:"acknowledged,omitempty"`
+}
+
+// statePath returns the location of the state file
+func statePath() string {
+	// the sandbox never shares recent items with the real vaults
+	if sandboxVault != "" {
+		return appFile(stateDir, "sandbox-state.json")
+	}
+	p := appFile(stateDir, "state.json")
+	migrateFile(p, "state.json")
+	return p
+}
+
+// loadState reads the state file, a missing or broken state file results
+// in an empty state
+func loadState() State {
+	var state State
+	if raw, err := os.ReadFile(statePath()); err == nil {
+		json.Unmarshal(raw, &state)
+	}
+	if state.Items == nil {
+		state.Items = make(map[string]ItemRef)
+	}
+	if state.Vaults == nil {
+		state.Vaults = make(map[string]string)
+	}
+	return state
+}
+
+// saveState writes the state file atomically
+func saveState(state State) error {
+	p := statePath()
+	if p == "" {
+		return nil
+	}
+	if err := ensurePrivateDir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), "state-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// updateState applies the update to the current state and saves it while
+// holding the lock of the state file, so parallel invocations like the
+// clones of submodules never lose each other's updates
+func updateState(update func(state *State)) error {
+	p := statePath()
+	if p == "" {
+		return nil
+	}
+	if err := ensurePrivateDir(filepath.Dir(p)); err != nil {
+		return err
+	}
+	unlock, err := lockFile(p + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state := loadState()
+	update(&state)
+	return saveState(state)
+}
+
+// stateKey returns the key of a request in the state
+func stateKey(host string, username string) string {
+	return username + "@" + host
+}
+
+// lookupRecentItem tries the item used last for the same host and username
+// first, this is a direct lookup by id and keeps the selection stable when
+// the contents of the vault change, otherwise the item is looked up as usual
+// and remembered for the next request
+func lookupRecentItem(vault string, host string, requestHost string, username string) (ItemRef, *OpFullItem, error) {
+	// the pinned item of a repository is not remembered for the host
+	if pinnedItem != "" {
+		return lookupItem(vault, host, requestHost, username)
+	}
+	state := loadState()
+	key := stateKey(requestHost, username)
+	if ref, ok := state.Items[key]; ok {
+		if item, err := opGetItem(ref); err == nil && usableItem(item, requestHost, "") {
+			if state.Vaults[requestHost] != item.Vault.ID {
+				updateState(func(state *State) { state.Vaults[requestHost] = item.Vault.ID })
+			}
+			return ref, item, nil
+		}
+	}
+
+	ref, item, err := lookupItem(vault, host, requestHost, username)
+	if err != nil {
+		return ref, nil, err
+	}
+	recent := ItemRef{Vault: item.Vault.ID, Item: item.ID}
+	if item.ID != "" && (state.Items[key] != recent || state.Vaults[requestHost] != recent.Vault) {
+		updateState(func(state *State) {
+			state.Items[key] = recent
+			state.Vaults[requestHost] = recent.Vault
+		})
+	}
+	return ref, item, nil
+}
@@ -0,0 +1,37 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// staleLock is the age after which a lock file is considered left over by
+// a crashed process
+const staleLock = 10 * time.Second
+
+// lockFile takes an exclusive lock by creating the file, other processes
+// wait until it is removed by the returned function
+func lockFile(p string) (func(), error) {
+	deadline := time.Now().Add(staleLock)
+	for {
+		f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(p) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(p); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(p)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("timed out waiting for the lock of the state")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
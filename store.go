@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// storeCredential persists the credential git approved, an existing item is
+// updated, otherwise a new item is created
+func storeCredential(gitInputs Attributes) error {
+	// short-lived credentials, e.g. minted by another helper, are useless
+	// once they expired
+	if gitBool(gitInputs.Get("ephemeral")) {
+		return nil
+	}
+	// without a host, e.g. the passphrase of a client certificate, there
+	// is no item to store the credential in
+	if gitInputs.Get("host") == "" {
+		return nil
+	}
+	if err := checkHost(gitInputs.Get("host")); err != nil {
+		return err
+	}
+	if err := checkProtocol(gitInputs.Get("protocol")); err != nil {
+		return err
+	}
+	// the item holds the long-lived token, git only knows the exchanged one,
+	// documents are maintained by the team distributing them
+	hc := hostConfig(routeHost(gitInputs))
+	if hc.Exchange != "" || hc.Document != "" {
+		return nil
+	}
+	// the item holds the token as password, whatever layout the host uses
+	gitInputs = unshapeCredential(hc, authTypeInputs(gitInputs))
+
+	// github.com and gitlab.com only accept tokens, do not persist a
+	// password that will never work
+	if err := checkToken(gitInputs.Get("host"), gitInputs.Get("password")); err != nil && config.PasswordPolicy != "off" {
+		if config.PasswordPolicy == "refuse" {
+			return err
+		}
+		warnf("%s", err)
+	}
+
+	requestHost, username := requestIdentity(gitInputs)
+	host := resolveHost(requestHost)
+	url := itemURL(gitInputs)
+	ref, item, _ := lookupRecentItem(storeVault(requestHost), host, requestHost, username)
+	if item != nil && item.ID != "" {
+		// edit the item by its id, titles are subject to ambiguous matching
+		ref = ItemRef{Vault: item.Vault.ID, Item: item.ID}
+	}
+	// prepared credentials like bearer tokens come without a username
+	if gitInputs.Get("authtype") != "" && gitInputs.Get("username") == "" && item != nil {
+		gitInputs = gitInputs.Set("username", item.username())
+	}
+	// a different username usually means the user authenticated with the
+	// wrong account and is about to clobber the right credential
+	if existing := item.username(); item != nil && existing != "" && existing != gitInputs.Get("username") && !force {
+		if config.ConfirmOverwrite {
+			if !confirm(fmt.Sprintf("Overwrite the credential of %q in %q with %q?", existing, item.Title, gitInputs.Get("username"))) {
+				return fmt.Errorf("refusing to overwrite the credential of %q with %q, use --force to overwrite", existing, gitInputs.Get("username"))
+			}
+		} else {
+			warnf("overwriting the credential of %q with %q in item %q, was the right account used?", existing, gitInputs.Get("username"), item.Title)
+		}
+	}
+
+	if item == nil {
+		if err := checkCreateLimit(); err != nil {
+			return err
+		}
+		if err := createItem(ref.Vault, ref.Item, url, gitInputs); err != nil {
+			if isPermissionDenied(err) {
+				return storeReadOnly(ref.Item, url, gitInputs)
+			}
+			return fmt.Errorf("op item create failed with %s", friendlyError(err))
+		}
+	} else if item.Fields.GetField("username") == gitInputs.Get("username") && item.Fields.GetField("password") == gitInputs.Get("password") &&
+		(gitInputs.Get("authtype") == "" || item.Fields.GetField("authtype") == gitInputs.Get("authtype")) {
+		// git calls store after every successful authentication, skip the
+		// edit when nothing changed to avoid churn in the item history
+		if !item.hasURLHost(gitInputs.Get("host")) {
+			err := retryOnConflict(func() error { return opAddItemURL(ref, url) })
+			if err != nil && isPermissionDenied(err) {
+				warnf("the vault of %q is read-only, the website was not added", item.Title)
+			} else if err != nil {
+				return friendlyError(err)
+			}
+		}
+	} else {
+		// run "op create edit" command to update the item, concurrent
+		// stores of the same item are retried
+		err := retryOnConflict(func() error {
+			if isMultiline(gitInputs.Get("username")) || isMultiline(gitInputs.Get("password")) {
+				if err := opSetCredential(ref, gitInputs.Get("username"), gitInputs.Get("password")); err != nil || gitInputs.Get("authtype") == "" {
+					return err
+				}
+				_, err := runOp(buildOpItemCommand("edit", ref.Vault, append([]string{ref.Item}, authTypeAssignment(gitInputs)...)...))
+				return err
+			}
+			assignments := append([]string{ref.Item, "username=" + gitInputs.Get("username"), "password=" + gitInputs.Get("password")}, authTypeAssignment(gitInputs)...)
+			_, err := runOp(buildOpItemCommand("edit", ref.Vault, assignments...))
+			return err
+		})
+		if err != nil && isPermissionDenied(err) {
+			return storeReadOnly(item.Title, url, gitInputs)
+		}
+		if err != nil {
+			return fmt.Errorf("op item edit failed with %s", friendlyError(err))
+		}
+		// keep the existing website entries and add the requested one
+		if !item.hasURLHost(gitInputs.Get("host")) {
+			if err := retryOnConflict(func() error { return opAddItemURL(ref, url) }); err != nil {
+				return friendlyError(err)
+			}
+		}
+	}
+	if config.KeychainFallback {
+		storeKeychain(requestHost, gitInputs.Get("username"), gitInputs.Get("password"))
+	}
+	return nil
+}
+
+// storeVault returns the vault get found the item of the host in, so store
+// updates that item instead of creating a duplicate in the configured vault
+func storeVault(requestHost string) string {
+	if found := loadState().Vaults[requestHost]; found != "" && pinnedItem == "" {
+		return found
+	}
+	return vault
+}
+
+// createItem runs "op item create" for a new login item, items with a
+// template or multi-line values are created from a template on stdin
+func createItem(vault string, title string, url string, gitInputs Attributes) error {
+	if err := checkItemArg(title); err != nil {
+		return err
+	}
+	template, err := itemTemplate(hostConfig(routeHost(gitInputs)))
+	if err != nil {
+		return err
+	}
+	args := []string{"--title=" + title, "--url=" + url}
+	// the category of the template wins, op defaults to the flag otherwise
+	if _, ok := template["category"]; !ok {
+		args = append(args, "--category=Login")
+	}
+	// --tags replaces the tags of the template
+	tags := templateTags(template)
+	if config.Tag != "" && !contains(tags, config.Tag) {
+		tags = append(tags, config.Tag)
+	}
+	if len(tags) > 0 {
+		args = append(args, "--tags="+strings.Join(tags, ","))
+	}
+	args = append(args, authTypeAssignment(gitInputs)...)
+	if template != nil || isMultiline(gitInputs.Get("username")) || isMultiline(gitInputs.Get("password")) {
+		raw, err := mergeCredential(template, gitInputs.Get("username"), gitInputs.Get("password"))
+		if err != nil {
+			return err
+		}
+		cmd := buildOpItemCommand("create", vault, args...)
+		cmd.Stdin = bytes.NewReader(raw)
+		if _, err = runOp(cmd); err == nil {
+			recordCreate()
+		}
+		return err
+	}
+	args = append(args, "username="+gitInputs.Get("username"), "password="+gitInputs.Get("password"))
+	if _, err = runOp(buildOpItemCommand("create", vault, args...)); err == nil {
+		recordCreate()
+	}
+	return err
+}
+
+// isPermissionDenied reports whether op failed because the vault is
+// read-only for the user
+func isPermissionDenied(err error) bool {
+	var helperErr *HelperError
+	return errors.As(friendlyError(err), &helperErr) && helperErr.Code == "permission_denied"
+}
+
+// storeReadOnly handles a store into a read-only vault (common for shared
+// team vaults): the item is created in the configured writable vault, or
+// the store is skipped with a message instead of failing git
+func storeReadOnly(title string, url string, gitInputs Attributes) error {
+	if config.WritableVault == "" {
+		warnf("the vault of %q is read-only, the credential was not stored", title)
+		return nil
+	}
+	if err := createItem(config.WritableVault, title, url, gitInputs); err != nil {
+		return fmt.Errorf("op item create failed with %s", friendlyError(err))
+	}
+	warnf("the vault of %q is read-only, the credential was stored in %q", title, config.WritableVault)
+	return nil
+}
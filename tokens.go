@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tokenProvider describes a git hosting service that no longer accepts
+// account passwords for git over https
+type tokenProvider struct {
+	// prefixes of the tokens issued by the provider
+	prefixes []string
+	// pattern of tokens without prefix, e.g. oauth tokens
+	pattern *regexp.Regexp
+	// where users can create a new token
+	tokenURL string
+}
+
+// tokenProviders maps hosts to their token formats
+// ref: https://github.blog/2021-04-05-behind-githubs-new-authentication-token-formats/
+// ref: https://docs.gitlab.com/ee/security/token_overview.html
+var tokenProviders = map[string]tokenProvider{
+	"github.com": {
+		prefixes: []string{"ghp_", "gho_", "ghu_", "ghs_", "ghr_", "github_pat_"},
+		// personal access tokens created before the prefixes were introduced
+		pattern:  regexp.MustCompile(`^[0-9a-f]{40}$`),
+		tokenURL: "https://github.com/settings/tokens",
+	},
+	"gitlab.com": {
+		prefixes: []string{"glpat-", "gloas-", "gldt-", "glcbt-", "glptt-", "glrt-", "glsoat-", "glffct-"},
+		pattern:  regexp.MustCompile(`^[0-9a-f]{64}$`),
+		tokenURL: "https://gitlab.com/-/user_settings/personal_access_tokens",
+	},
+}
+
+// checkToken returns an error if the password is sent to a host that only
+// accepts tokens but does not look like one of its tokens
+func checkToken(host string, password string) error {
+	provider, ok := tokenProviders[host]
+	if !ok || password == "" {
+		return nil
+	}
+	for _, prefix := range provider.prefixes {
+		if strings.HasPrefix(password, prefix) {
+			return nil
+		}
+	}
+	if provider.pattern != nil && provider.pattern.MatchString(password) {
+		return nil
+	}
+	return fmt.Errorf("%s does not accept account passwords for git, the password does not look like a token. Create one at %s", host, provider.tokenURL)
+}
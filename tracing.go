@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TracingConfig enables the export of traces to an OTLP/HTTP collector
+type TracingConfig struct {
+	// Endpoint is the base URL of the collector, e.g.
+	// "https://otel.example.com:4318", traces are sent to /v1/traces
+	Endpoint string `json:"endpoint"`
+
+	// Headers are sent with every export, e.g. for authentication
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// span is a single traced operation, spans never hold secrets: op calls only
+// record the command, not their arguments, input or output
+type span struct {
+	id     string
+	parent string
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  map[string]string
+	err    string
+}
+
+var (
+	traceID  string
+	rootSpan *span
+	spans    []*span
+)
+
+// randomID returns a random hex id of n bytes
+func randomID(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startTrace starts the root span of the action, it does nothing unless
+// tracing is configured
+func startTrace(action string) {
+	if config.Tracing == nil || config.Tracing.Endpoint == "" {
+		return
+	}
+	traceID = randomID(16)
+	rootSpan = &span{id: randomID(8), name: action, start: time.Now(), attrs: map[string]string{"helper.action": action}}
+	spans = append(spans, rootSpan)
+	log.SetOutput(traceLogWriter{})
+}
+
+// traceOp starts a child span for an op call, the returned function ends it
+func traceOp(args []string) func(error) {
+	if rootSpan == nil {
+		return func(error) {}
+	}
+	// only the command words, the remaining arguments may contain item
+	// names or a session token
+	var command []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") || len(command) == 2 {
+			break
+		}
+		command = append(command, arg)
+	}
+	s := &span{id: randomID(8), parent: rootSpan.id, name: "op " + strings.Join(command, " "), start: time.Now(),
+		attrs: map[string]string{"op.command": strings.Join(command, " "), "op.daemon": strconv.FormatBool(config.Daemon || cacheOnly)}}
+	spans = append(spans, s)
+	return func(err error) {
+		s.end = time.Now()
+		// only the classification, the output of op may contain item names
+		var helperErr *HelperError
+		if errors.As(friendlyError(err), &helperErr) {
+			s.err = helperErr.Code
+		} else if err != nil {
+			s.err = "op failed"
+		}
+	}
+}
+
+// finishTrace ends the root span and exports all spans
+func finishTrace(err string) {
+	if rootSpan == nil {
+		return
+	}
+	rootSpan.end = time.Now()
+	rootSpan.err = err
+	exportTrace()
+	rootSpan = nil
+}
+
+// traceLogWriter passes log output to stderr and ends the trace with an error
+// on fatal errors, which are all log lines except warnings
+type traceLogWriter struct{}
+
+func (traceLogWriter) Write(p []byte) (int, error) {
+	if line := string(p); !strings.Contains(line, "warning:") {
+		finishTrace(traceErrorCode(line))
+	}
+	return os.Stderr.Write(p)
+}
+
+// traceErrorCode returns the code of the error in a fatal log line like the
+// machine-readable output does, the line itself may hold the raw output of
+// op and is never exported
+func traceErrorCode(line string) string {
+	for _, class := range errorClasses {
+		if strings.Contains(line, class.message) {
+			return class.code
+		}
+	}
+	return "error"
+}
+
+// otlpAttributes converts attributes to the OTLP JSON encoding
+func otlpAttributes(attrs map[string]string) []map[string]any {
+	result := make([]map[string]any, 0, len(attrs))
+	for k, v := range attrs {
+		result = append(result, map[string]any{"key": k, "value": map[string]string{"stringValue": v}})
+	}
+	return result
+}
+
+// exportTrace sends the spans to the collector, failures are ignored as
+// tracing must never break git
+func exportTrace() {
+	otlpSpans := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		if s.end.IsZero() {
+			s.end = time.Now()
+		}
+		status := map[string]any{"code": 1}
+		if s.err != "" {
+			status = map[string]any{"code": 2, "message": s.err}
+		}
+		otlpSpans = append(otlpSpans, map[string]any{
+			"traceId":           traceID,
+			"spanId":            s.id,
+			"parentSpanId":      s.parent,
+			"name":              s.name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+			"status":            status,
+		})
+	}
+	resource := map[string]string{
+		"service.name":    "git-credential-1password",
+		"service.version": version,
+		"os.type":         runtime.GOOS,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		resource["host.name"] = hostname
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource":   map[string]any{"attributes": otlpAttributes(resource)},
+			"scopeSpans": []any{map[string]any{"scope": map[string]string{"name": "git-credential-1password"}, "spans": otlpSpans}},
+		}},
+	})
+	if err != nil {
+		return
+	}
+
+	endpoint, err := url.JoinPath(config.Tracing.Endpoint, "v1", "traces")
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range config.Tracing.Headers {
+		req.Header.Set(k, v)
+	}
+	client := http.Client{Timeout: 2 * time.Second}
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stopDaemon asks a running daemon to exit
+func stopDaemon() bool {
+	conn, err := net.DialTimeout("unix", daemonSocketPath(), 100*time.Millisecond)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	if checkPeer(conn) != nil {
+		return false
+	}
+	return json.NewEncoder(conn).Encode(daemonRequest{Shutdown: true}) == nil
+}
+
+// removeGitConfig removes all credential helper entries of this helper
+// from the global git config
+func removeGitConfig() []string {
+	output, err := exec.Command("git", "config", "--global", "--get-regexp", `^credential\..*helper$`).Output()
+	if err != nil {
+		return nil
+	}
+	var removed []string
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		if !strings.Contains(value, "1password") {
+			continue
+		}
+		if exec.Command("git", "config", "--global", "--unset-all", key, "1password").Run() == nil {
+			removed = append(removed, key+" "+value)
+		}
+	}
+	return removed
+}
+
+// archiveTaggedItems moves all items with the helper tag to the archive
+func archiveTaggedItems() error {
+	raw, err := runOp(buildOpItemCommand("list", vault, "--tags", config.Tag, "--format", "json"))
+	if err != nil {
+		return fmt.Errorf("opItemList failed with %s", err)
+	}
+	var items []OpListItem
+	if err = json.Unmarshal(raw, &items); err != nil {
+		return fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	return archiveItems(items)
+}
+
+// archiveItems moves the items to the archive of their vault
+func archiveItems(items []OpListItem) error {
+	for _, item := range items {
+		if _, err := runOp(buildOpItemCommand("delete", item.Vault.ID, item.ID, "--archive")); err != nil {
+			return fmt.Errorf("archiving %q failed with %s", item.Title, friendlyError(err))
+		}
+		fmt.Printf("Archived %q\n", item.Title)
+	}
+	return nil
+}
+
+// Uninstall tears the helper down: it removes the git config entries,
+// stops the daemon, deletes the local state, cache and keyring entries and
+// optionally archives the items tagged by the helper
+func Uninstall(args []string) error {
+	flags := flag.NewFlagSet("uninstall", flag.ExitOnError)
+	archive := flags.Bool("archive-items", false, "Archive all items with the helper tag")
+	yes := flags.Bool("yes", false, "Do not ask for confirmation")
+	flags.Parse(args)
+
+	if !*yes && !confirm("Remove the git config entries and all local data of git-credential-1password?") {
+		return fmt.Errorf("uninstall aborted")
+	}
+
+	for _, entry := range removeGitConfig() {
+		fmt.Printf("Removed git config %s\n", entry)
+	}
+	flushLocalData()
+	if p := statePath(); p != "" && os.Remove(p) == nil {
+		fmt.Printf("Removed %s\n", p)
+		os.Remove(p + ".lock")
+	}
+	// only empty directories are removed, the config is handled below
+	for _, kind := range []dirKind{stateDir, cacheDir, runtimeDir} {
+		if dir := appDir(kind); dir != "" {
+			os.Remove(dir)
+		}
+	}
+
+	if *archive {
+		if config.Tag == "" {
+			return fmt.Errorf("no tag configured, the items of the helper can not be identified")
+		}
+		if !*yes && !confirm(fmt.Sprintf("Archive all items tagged %q?", config.Tag)) {
+			return nil
+		}
+		if err := archiveTaggedItems(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(configPath); err == nil && (*yes || confirm("Remove the config file "+configPath+"?")) {
+		os.Remove(configPath)
+		os.Remove(filepath.Dir(configPath))
+		fmt.Printf("Removed %s\n", configPath)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// defaultUnlockWait is how long get waits for 1Password to be unlocked
+	// when the config does not set unlockWait
+	defaultUnlockWait = 30 * time.Second
+	// unlockPollInterval is how often "op whoami" checks for the unlock
+	unlockPollInterval = time.Second
+)
+
+// unlockWait returns how long to wait for an unlock, zero disables waiting
+func unlockWait() time.Duration {
+	if config.UnlockWait != nil {
+		return time.Duration(*config.UnlockWait)
+	}
+	return defaultUnlockWait
+}
+
+// isLocked reports whether op failed because the 1Password app is locked. A
+// denied or dismissed authorization prompt was the choice of the user and is
+// never waited on, polling would only raise the prompt again, and a missing
+// account or an expired session does not go away by waiting either
+func isLocked(err error) bool {
+	var helperErr *HelperError
+	if !errors.As(friendlyError(err), &helperErr) {
+		return false
+	}
+	return helperErr.Code == "locked"
+}
+
+// waitForUnlock polls op until 1Password is unlocked or the window passed
+func waitForUnlock(window time.Duration) bool {
+	deadline := time.Now().Add(window)
+	for time.Now().Before(deadline) {
+		time.Sleep(unlockPollInterval)
+		if opUnlocked() {
+			return true
+		}
+	}
+	return false
+}
+
+// getCredentialAfterUnlock looks up the credential and, when 1Password is
+// locked, waits for the user to unlock it and retries the lookup once,
+// instead of failing git and having the user run it again
+func getCredentialAfterUnlock(gitInputs Attributes) (*Credential, error) {
+	credential, err := getCredential(gitInputs)
+	if err == nil || !isLocked(err) || ciMode || cacheOnly || isBackground() {
+		return credential, err
+	}
+	window := unlockWait()
+	if window <= 0 {
+		return credential, err
+	}
+	warnf("1Password is locked, unlock it within %s to continue", window)
+	if !waitForUnlock(window) {
+		return credential, err
+	}
+	return getCredential(gitInputs)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// OpURL is a website entry of an item
+type OpURL struct {
+	Label   string `json:"label,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Href    string `json:"href"`
+}
+
+// OpListItem is an entry of the output of "op item list --format json"
+type OpListItem struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Vault     OpVault   `json:"vault"`
+	Category  string    `json:"category,omitempty"`
+	Favorite  bool      `json:"favorite,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	URLs      []OpURL   `json:"urls,omitempty"`
+}
+
+// rankItems orders candidates so that favorites come first, followed by the
+// most recently edited items, so the item the user maintains wins over stale
+// duplicates
+func rankItems(items []OpListItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Favorite != items[j].Favorite {
+			return items[i].Favorite
+		}
+		return items[i].UpdatedAt.After(items[j].UpdatedAt)
+	})
+}
+
+// itemURL returns the website entry of the item for a request of git, git
+// may call store without the protocol get was asked with, the remotes of
+// credentials are https then
+func itemURL(gitInputs Attributes) string {
+	protocol := gitInputs.Get("protocol")
+	if protocol == "" {
+		protocol = "https"
+	}
+	return canonicalURL(protocol + "://" + gitInputs.Get("host"))
+}
+
+// defaultPorts are the ports dropped from website entries of the scheme
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ssh": "22", "git": "9418"}
+
+// parseHref parses a website entry, entries without scheme are treated as
+// https
+func parseHref(href string) (*url.URL, error) {
+	if !strings.Contains(href, "://") {
+		href = "https://" + href
+	}
+	return url.Parse(href)
+}
+
+// canonicalURL normalizes a website entry: the scheme and host are lower
+// case, credentials, the fragment, the default port of the scheme and a
+// trailing slash are dropped. Entries that do not parse are kept as is
+func canonicalURL(href string) string {
+	u, err := parseHref(href)
+	if err != nil || u.Host == "" {
+		return href
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if port := u.Port(); port != "" && defaultPorts[u.Scheme] == port {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// urlHost returns the host of a website entry without the default port of
+// its scheme
+func urlHost(href string) string {
+	u, err := parseHref(canonicalURL(href))
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// matchesHost reports whether any of the items website entries points to
+// host, default ports are ignored on both sides
+func (i OpListItem) matchesHost(host string) bool {
+	for _, u := range i.URLs {
+		if strings.EqualFold(urlHost(u.Href), urlHost(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasURLHost reports whether any of the items website entries, or the
+// server of a Database item, points to host
+func (i OpFullItem) hasURLHost(host string) bool {
+	urls := i.URLs
+	if i.Category == databaseCategory {
+		urls = append(urls, databaseURLs(i.Fields)...)
+	}
+	return OpListItem{URLs: urls}.matchesHost(host)
+}
+
+// opListItems runs "op item list --format json" for items of all categories,
+// e.g. API Credential items carry a website entry as well, the server of
+// database items is added to their website entries
+func opListItems(vault string) ([]OpListItem, error) {
+	opItemList := buildOpItemCommand("list", vault, "--format", "json")
+	opItemRaw, err := runOp(opItemList)
+	if err != nil {
+		return nil, fmt.Errorf("opItemList failed with %s", err)
+	}
+
+	var items []OpListItem
+	if err = json.Unmarshal(opItemRaw, &items); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	addDatabaseURLs(items)
+	return items, nil
+}
+
+// findItems searches the vault for items titled title (with or without a
+// username suffix) and items with a website entry for one of the hosts, the
+// ranked title matches come before the ranked website matches
+func findItems(title string, hosts []string, vault string) []ItemRef {
+	items, err := opListItems(vault)
+	if err != nil {
+		return nil
+	}
+
+	var byTitle, byURL []OpListItem
+	for _, item := range items {
+		if item.Title == title || strings.HasPrefix(item.Title, title+titleSeparator) {
+			byTitle = append(byTitle, item)
+			continue
+		}
+		for _, host := range hosts {
+			if item.matchesHost(host) {
+				byURL = append(byURL, item)
+				break
+			}
+		}
+	}
+	rankItems(byTitle)
+	rankItems(byURL)
+
+	var refs []ItemRef
+	for _, item := range append(byTitle, byURL...) {
+		refs = append(refs, ItemRef{Vault: vault, Item: item.ID})
+	}
+	return refs
+}
+
+// itemIDPattern matches the unique ids 1Password assigns to items
+var itemIDPattern = regexp.MustCompile(`^[a-z0-9]{26}$`)
+
+// opResolveItem resolves an item title to the unique id of the item, only
+// items with exactly that title are considered, so mutations never hit a
+// similarly named item
+func opResolveItem(ref ItemRef) (ItemRef, error) {
+	if itemIDPattern.MatchString(ref.Item) {
+		return ref, nil
+	}
+	items, err := opListItems(ref.Vault)
+	if err != nil {
+		return ref, err
+	}
+	var matches []OpListItem
+	for _, item := range items {
+		if item.Title == ref.Item {
+			matches = append(matches, item)
+		}
+	}
+	if len(matches) == 0 {
+		return ref, fmt.Errorf("no item titled %q found", ref.Item)
+	}
+	rankItems(matches)
+	return ItemRef{Vault: ref.Vault, Item: matches[0].ID}, nil
+}
+
+// maxHostLength is the longest host accepted, a DNS name with a port
+const maxHostLength = 253 + len(":65535")
+
+// protocolPattern matches the URL schemes git may send as protocol
+var protocolPattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// hasControl reports whether s contains control characters, remote URLs are
+// attacker-influenced and such characters could spoof terminal output or
+// item titles
+func hasControl(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
+
+// checkHost rejects hosts git would never send for a https remote, like
+// hosts with user info, paths or a leading "-" which op would parse as a flag
+func checkHost(host string) error {
+	if len(host) > maxHostLength {
+		return fmt.Errorf("invalid host %.32q..., the host is longer than %d characters", host, maxHostLength)
+	}
+	if hasControl(host) {
+		return fmt.Errorf("invalid host %q, the host must not contain control characters", host)
+	}
+	if strings.Contains(host, "@") {
+		return fmt.Errorf("invalid host %q, the host must not contain user info", host)
+	}
+	if strings.HasPrefix(host, "-") {
+		return fmt.Errorf("invalid host %q, the host must not start with \"-\"", host)
+	}
+	if strings.ContainsAny(host, "/\\?# \t\r\n\x00") {
+		return fmt.Errorf("invalid host %q", host)
+	}
+	return nil
+}
+
+// checkItemArg rejects item names that op would parse as a flag and names
+// with control characters
+func checkItemArg(item string) error {
+	if strings.HasPrefix(item, "-") {
+		return fmt.Errorf("invalid item name %q, the name must not start with \"-\"", item)
+	}
+	if hasControl(item) {
+		return fmt.Errorf("invalid item name %q, the name must not contain control characters", item)
+	}
+	return nil
+}
+
+// checkProtocol rejects protocols that are not a URL scheme, the protocol
+// ends up in the website of stored items
+func checkProtocol(protocol string) error {
+	if protocol != "" && !protocolPattern.MatchString(protocol) {
+		return fmt.Errorf("invalid protocol %q", protocol)
+	}
+	return nil
+}
+
+// opAddItemURL appends href in canonical form to the website entries of the
+// item unless an entry for the same host already exists, entries differing
+// only in case or the default port count as the same host. All other entries
+// are kept as they are
+func opAddItemURL(ref ItemRef, href string) error {
+	if err := checkItemArg(ref.Item); err != nil {
+		return err
+	}
+	opItemRaw, err := runOp(buildOpItemCommand("get", ref.Vault, "--format", "json", ref.Item))
+	if err != nil {
+		return fmt.Errorf("opItemGet failed with %s", err)
+	}
+
+	// keep the raw item so that no attributes get lost when editing it
+	var item map[string]json.RawMessage
+	if err = json.Unmarshal(opItemRaw, &item); err != nil {
+		return fmt.Errorf("json.Unmarshal() failed with %s", err)
+	}
+	var urls []OpURL
+	if raw, ok := item["urls"]; ok {
+		if err = json.Unmarshal(raw, &urls); err != nil {
+			return fmt.Errorf("json.Unmarshal() failed with %s", err)
+		}
+	}
+	if (OpListItem{URLs: urls}).matchesHost(urlHost(href)) {
+		return nil
+	}
+	urls = append(urls, OpURL{Label: "website", Primary: len(urls) == 0, Href: canonicalURL(href)})
+	if item["urls"], err = json.Marshal(urls); err != nil {
+		return err
+	}
+	template, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	// "op item edit" reads the edited item from stdin
+	opItemEdit := buildOpItemCommand("edit", ref.Vault, ref.Item)
+	opItemEdit.Stdin = bytes.NewReader(template)
+	if _, err := runOp(opItemEdit); err != nil {
+		return fmt.Errorf("op item edit failed with %s", err)
+	}
+	return nil
+}
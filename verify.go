@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// verifyHost is the host the credentials of verify-protocol are stored
+// under, the .invalid TLD never resolves
+const verifyHost = "verify-protocol.git-credential-1password.invalid"
+
+// verifyCase is a credential stored, filled and erased through git
+type verifyCase struct {
+	name     string
+	protocol string
+	host     string
+	username string
+	password string
+	// extra are attributes added to the request, like consumers such as
+	// git svn or deployment scripts send them
+	extra string
+}
+
+var verifyCases = []verifyCase{
+	{"plain", "https", verifyHost, "alice", "s3cret", ""},
+	{"equals sign", "https", verifyHost, "alice", "a=b==c", ""},
+	{"spaces", "https", verifyHost, "alice", "  leading and trailing  ", ""},
+	{"percent encoding", "https", verifyHost, "alice", "p%40ss%20word", ""},
+	{"unicode", "https", verifyHost, "jürgen", "пароль密码🔑", ""},
+	{"email username", "https", verifyHost, "alice@example.com", "s3cret", ""},
+	{"port", "https", verifyHost + ":8443", "alice", "s3cret", ""},
+	{"http", "http", verifyHost, "alice", "s3cret", ""},
+	{"svn", "svn", verifyHost, "alice", "s3cret", "path=repos/trunk\n"},
+	{"unknown attributes", "https", verifyHost, "alice", "s3cret", "x-deploy-env=staging\nx-deploy-id=42\n"},
+}
+
+// gitCredential runs "git credential <action>" with only this helper
+// configured and returns the attributes git printed
+func gitCredential(helper string, action string, input string) (Attributes, error) {
+	cmd := exec.Command("git", "-c", "credential.helper=", "-c", "credential.helper="+helper, "credential", action)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git credential %s failed with %s\n%s", action, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return parseAttributes(&stdout)
+}
+
+// helperGet runs the helper directly, like tools do that bypass git, and
+// returns the attributes it printed
+func helperGet(helper []string, input string) (Attributes, error) {
+	cmd := exec.Command(helper[0], append(helper[1:], "get")...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("get failed with %s\n%s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return parseAttributes(&stdout)
+}
+
+// checkEcho checks that the helper echoes the unknown attributes of the
+// request unchanged, git itself drops attributes it does not know
+func checkEcho(helper []string, request string, extra string) error {
+	attrs, err := helperGet(helper, request+extra+"\n")
+	if err != nil {
+		return err
+	}
+	want, err := parseAttributes(strings.NewReader(extra))
+	if err != nil {
+		return err
+	}
+	for _, attr := range unknownAttributes(want) {
+		if !contains(attrs.Values(attr.Key), attr.Value) {
+			return fmt.Errorf("get did not echo %s=%s", attr.Key, attr.Value)
+		}
+	}
+	return nil
+}
+
+// verifyOne stores, fills and erases the credential of the case through git,
+// helper is the command line of the helper for direct calls
+func verifyOne(helper []string, c verifyCase) error {
+	gitHelper := "!" + shellQuote(helper[0])
+	for _, arg := range helper[1:] {
+		gitHelper += " " + shellQuote(arg)
+	}
+	request := fmt.Sprintf("protocol=%s\nhost=%s\n", c.protocol, c.host)
+	credential := request + fmt.Sprintf("username=%s\npassword=%s\n", c.username, c.password)
+
+	if _, err := gitCredential(gitHelper, "approve", credential+"\n"); err != nil {
+		return err
+	}
+	attrs, err := gitCredential(gitHelper, "fill", request+c.extra+"\n")
+	if err != nil {
+		gitCredential(gitHelper, "reject", credential+"\n")
+		return err
+	}
+	if attrs.Get("username") != c.username || attrs.Get("password") != c.password {
+		gitCredential(gitHelper, "reject", credential+"\n")
+		return fmt.Errorf("fill returned username %q password %q, expected %q %q", attrs.Get("username"), attrs.Get("password"), c.username, c.password)
+	}
+	if c.extra != "" {
+		if err := checkEcho(helper, request, c.extra); err != nil {
+			gitCredential(gitHelper, "reject", credential+"\n")
+			return err
+		}
+	}
+	if _, err := gitCredential(gitHelper, "reject", credential+"\n"); err != nil {
+		return err
+	}
+	if _, err := gitCredential(gitHelper, "fill", request+"\n"); err == nil {
+		return fmt.Errorf("fill still returned a credential after reject")
+	}
+	return nil
+}
+
+// VerifyProtocol drives the installed git against the helper with crafted
+// credentials and checks that every credential survives the round trip
+// through approve, fill and reject
+func VerifyProtocol() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// the helper is run with the same options as this invocation
+	helper := append([]string{exe}, os.Args[1:len(os.Args)-flag.NArg()]...)
+
+	gitVersion, err := exec.Command("git", "--version").Output()
+	if err != nil {
+		return fmt.Errorf("git --version failed with %s", err)
+	}
+	fmt.Printf("%s", gitVersion)
+	fmt.Printf("Storing test credentials for %s\n", verifyHost)
+
+	failed := 0
+	for _, c := range verifyCases {
+		if err := verifyOne(helper, c); err != nil {
+			failed++
+			fmt.Printf("%s %s: %s\n", red("FAIL"), c.name, err)
+			continue
+		}
+		fmt.Printf("%s   %s\n", green("ok"), c.name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(verifyCases))
+	}
+	return nil
+}